@@ -0,0 +1,289 @@
+package log4go
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AdminHandler http handler to inspect and change the log levels at runtime:
+//
+//	GET /                   the logger level and the writers with their levels
+//	PUT /                   set the logger level, body {"level":"DEBUG","ttl":"10m"}
+//	PUT /writers/{id}       set the writer level, id is the index or the name
+//
+// The writers are those of the logger then those of its named loggers, the
+// name of a named logger writer starts with "<logger>/", ex: com.team/file.
+//
+// level and ttl may be given as query parameters too. After ttl the level
+// reverts to the one before the first temporary change, a PUT without ttl
+// makes the change permanent.
+type AdminHandler struct {
+	logger *Logger
+
+	lock    sync.Mutex
+	reverts map[interface{}]*levelRevert // keyed by the logger or the writer
+}
+
+type levelRevert struct {
+	timer *time.Timer
+	level int       // reverted to
+	at    time.Time // revert time
+}
+
+// AdminLevel level change request
+type AdminLevel struct {
+	Level string `json:"level"`
+	TTL   string `json:"ttl,omitempty"` // time.Duration, ex: 10m
+}
+
+// AdminWriter writer state
+type AdminWriter struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Logger   string `json:"logger,omitempty"` // the named logger, empty for the root
+	Type     string `json:"type"`
+	Level    string `json:"level,omitempty"`
+	RevertAt string `json:"revert_at,omitempty"`
+}
+
+// AdminState logger state
+type AdminState struct {
+	Level    string            `json:"level"`
+	RevertAt string            `json:"revert_at,omitempty"`
+	Writers  []AdminWriter     `json:"writers"`
+	Dropped  map[string]uint64 `json:"dropped"`
+	Redacted map[string]uint64 `json:"redacted,omitempty"` // masked values per redaction rule
+}
+
+// NewAdminHandler create admin handler of the logger, loggerDefault if l is nil.
+// Mount it with http.StripPrefix, ex:
+//
+//	http.Handle("/debug/log4go/", http.StripPrefix("/debug/log4go", log4go.NewAdminHandler(nil)))
+func NewAdminHandler(l *Logger) *AdminHandler {
+	return &AdminHandler{logger: l, reverts: make(map[interface{}]*levelRevert)}
+}
+
+func (h *AdminHandler) target() *Logger {
+	if h.logger != nil {
+		return h.logger
+	}
+	return loggerDefault
+}
+
+// ServeHTTP serve the admin requests
+func (h *AdminHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	p := strings.Trim(req.URL.Path, "/")
+	switch {
+	case p == "" && req.Method == http.MethodGet:
+		h.writeState(rw)
+	case p == "" && req.Method == http.MethodPut:
+		h.putLoggerLevel(rw, req)
+	case strings.HasPrefix(p, "writers/") && req.Method == http.MethodPut:
+		h.putWriterLevel(rw, req, strings.TrimPrefix(p, "writers/"))
+	case p == "" || strings.HasPrefix(p, "writers/"):
+		rw.Header().Set("Allow", "GET, PUT")
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(rw, req)
+	}
+}
+
+// State the logger state
+func (h *AdminHandler) State() AdminState {
+	l := h.target()
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	state := AdminState{
+		Level:    LevelFlags[l.Level()],
+		RevertAt: h.revertAt(l.loggerCore),
+		Dropped:  l.Dropped(),
+	}
+	if rd := l.Redactor(); rd != nil {
+		state.Redacted = rd.Hits()
+	}
+	for i, nw := range namedWriters(l) {
+		w := nw.w
+		aw := AdminWriter{ID: i, Name: nw.name, Logger: nw.logger, Type: writerType(w), RevertAt: h.revertAt(w)}
+		if lw, ok := w.(Leveler); ok && lw.Level() >= 0 && lw.Level() < len(LevelFlags) {
+			aw.Level = LevelFlags[lw.Level()]
+		}
+		state.Writers = append(state.Writers, aw)
+	}
+	return state
+}
+
+func (h *AdminHandler) writeState(rw http.ResponseWriter) {
+	rw.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(rw)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(h.State())
+}
+
+func (h *AdminHandler) putLoggerLevel(rw http.ResponseWriter, req *http.Request) {
+	level, ttl, err := parseAdminLevel(req)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	l := h.target()
+	h.setLevel(l.loggerCore, l.Level, l.SetLevel, level, ttl)
+	h.writeState(rw)
+}
+
+func (h *AdminHandler) putWriterLevel(rw http.ResponseWriter, req *http.Request, id string) {
+	l := h.target()
+	var found Writer
+	for i, nw := range namedWriters(l) {
+		if id == strconv.Itoa(i) || id == nw.name {
+			found = nw.w
+			break
+		}
+	}
+	if found == nil {
+		http.Error(rw, "writer "+id+" not found", http.StatusNotFound)
+		return
+	}
+	lw, ok := found.(Leveler)
+	if !ok {
+		http.Error(rw, "writer "+id+" has no level", http.StatusBadRequest)
+		return
+	}
+	level, ttl, err := parseAdminLevel(req)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.setLevel(found, lw.Level, lw.SetLevel, level, ttl)
+	h.writeState(rw)
+}
+
+// setLevel set the level of the key, revert it after ttl if ttl > 0
+func (h *AdminHandler) setLevel(key interface{}, get func() int, set func(int), level int, ttl time.Duration) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	rv, pending := h.reverts[key]
+	if pending {
+		rv.timer.Stop()
+		delete(h.reverts, key)
+	}
+	if ttl > 0 {
+		if !pending {
+			rv = &levelRevert{level: get()}
+		}
+		rv.at = time.Now().Add(ttl)
+		rv.timer = time.AfterFunc(ttl, func() {
+			h.lock.Lock()
+			defer h.lock.Unlock()
+			if h.reverts[key] == rv {
+				set(rv.level)
+				delete(h.reverts, key)
+			}
+		})
+		h.reverts[key] = rv
+	}
+	set(level)
+}
+
+// revertAt the revert time of a temporary level, called with h.lock held
+func (h *AdminHandler) revertAt(key interface{}) string {
+	if rv, ok := h.reverts[key]; ok {
+		return rv.at.Format(time.RFC3339)
+	}
+	return ""
+}
+
+func parseAdminLevel(req *http.Request) (int, time.Duration, error) {
+	var al AdminLevel
+	if req.Body != nil && req.ContentLength != 0 {
+		if err := json.NewDecoder(req.Body).Decode(&al); err != nil {
+			return 0, 0, err
+		}
+	}
+	if v := req.URL.Query().Get("level"); v != "" {
+		al.Level = v
+	}
+	if v := req.URL.Query().Get("ttl"); v != "" {
+		al.TTL = v
+	}
+
+	level := getLevel(al.Level)
+	if level < 0 {
+		return 0, 0, errors.New("invalid level (" + al.Level + ")")
+	}
+	var ttl time.Duration
+	if al.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(al.TTL); err != nil {
+			return 0, 0, err
+		}
+		if ttl <= 0 {
+			return 0, 0, errors.New("invalid ttl (" + al.TTL + "), must be > 0")
+		}
+	}
+	return level, ttl, nil
+}
+
+// namedWriter a writer of the logger or of one of its named loggers
+type namedWriter struct {
+	name   string
+	logger string // empty for the root
+	w      Writer
+}
+
+// namedWriters the writers of the logger then those of the named loggers, in
+// creation order
+func namedWriters(l *Logger) []namedWriter {
+	var writers []namedWriter
+	for _, w := range l.Writers() {
+		writers = append(writers, namedWriter{name: writerName(l, w), w: w})
+	}
+	l.namesLock.Lock()
+	nodes := l.nodes
+	l.namesLock.Unlock()
+	for _, n := range nodes {
+		for _, w := range n.logger.Writers() {
+			name := writerName(l, w)
+			if !strings.HasPrefix(name, n.name+"/") {
+				name = n.name + "/" + name
+			}
+			writers = append(writers, namedWriter{name: name, logger: n.name, w: w})
+		}
+	}
+	return writers
+}
+
+// writerName the name in the config for the writers created by SetupLog, else the type
+func writerName(l *Logger, w Writer) string {
+	if l == loggerDefault {
+		running.Lock()
+		defer running.Unlock()
+		for name, rw := range running.writers {
+			if rw == w {
+				return name
+			}
+		}
+	}
+	return writerType(w)
+}
+
+func writerType(w Writer) string {
+	if aw, ok := w.(*AsyncWriter); ok {
+		return "async(" + writerType(aw.Unwrap()) + ")"
+	}
+	if hw, ok := w.(*HookWriter); ok {
+		return "hook(" + writerType(hw.Unwrap()) + ")"
+	}
+	t := reflect.TypeOf(w)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
@@ -0,0 +1,79 @@
+package log4go
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestAdminPutLevelTTL(t *testing.T) {
+	l, _ := newTestLogger(t)
+	l.SetLevel(INFO)
+	h := NewAdminHandler(l)
+
+	for _, tc := range []struct {
+		query string
+		code  int
+		level int
+	}{
+		{"level=DEBUG&ttl=-5m", http.StatusBadRequest, INFO},
+		{"level=DEBUG&ttl=0s", http.StatusBadRequest, INFO},
+		{"level=DEBUG&ttl=bad", http.StatusBadRequest, INFO},
+		{"level=DEBUG&ttl=1h", http.StatusOK, DEBUG},
+		{"level=WARN", http.StatusOK, WARNING},
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/?"+tc.query, nil))
+		if rec.Code != tc.code {
+			t.Errorf("%s: code = %d, want %d", tc.query, rec.Code, tc.code)
+		}
+		if got := l.Level(); got != tc.level {
+			t.Errorf("%s: level = %s, want %s", tc.query, LevelFlags[got], LevelFlags[tc.level])
+		}
+	}
+}
+
+func TestAdminNamedLoggerWriters(t *testing.T) {
+	dir := tempDir(t)
+	t.Cleanup(func() { _ = SetupLog(LogConfig{}) })
+	err := SetupLog(LogConfig{
+		Level: "INFO",
+		Loggers: []ConfLogger{{
+			Name:       "com.team",
+			FileWriter: ConfFileWriter{Enable: true, PathPattern: filepath.Join(dir, "team.log")},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	console := NewConsoleWriterWithLevel(WARNING, &ConfConsoleWriter{})
+	GetLogger("com.team.db").Register(console)
+	t.Cleanup(func() { GetLogger("com.team.db").Unregister(console) })
+	h := NewAdminHandler(nil)
+
+	writers := make(map[string]AdminWriter)
+	for _, aw := range h.State().Writers {
+		writers[aw.Name] = aw
+	}
+	if aw := writers["com.team/file"]; aw.Logger != "com.team" || aw.Level != "TRACE" {
+		t.Errorf("com.team/file = %+v, in %v", aw, writers)
+	}
+	if aw := writers["com.team.db/ConsoleWriter"]; aw.Logger != "com.team.db" || aw.Level != "WARN" {
+		t.Errorf("com.team.db/ConsoleWriter = %+v, in %v", aw, writers)
+	}
+
+	for name, want := range map[string]int{"com.team/file": ERROR, "com.team.db/ConsoleWriter": DEBUG} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/writers/"+name+"?level="+LevelFlags[want], nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("PUT %s: code = %d, %s", name, rec.Code, rec.Body.String())
+		}
+	}
+	if got := running.writers["com.team/file"].(Leveler).Level(); got != ERROR {
+		t.Errorf("com.team/file level = %s", LevelFlags[got])
+	}
+	if got := console.Level(); got != DEBUG {
+		t.Errorf("console level = %s", LevelFlags[got])
+	}
+}
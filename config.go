@@ -2,8 +2,12 @@ package log4go
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"reflect"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/kdpujie/log4go/util"
@@ -14,19 +18,28 @@ var GlobalLevel = DEBUG
 
 // ConfFileWriter file writer config
 type ConfFileWriter struct {
-	Level       string `json:"level" mapstructure:"level"`
+	Level       string `json:"level" mapstructure:"level"` // empty means TRACE, the logger level filters
 	PathPattern string `json:"path_pattern" mapstructure:"path_pattern"`
 	Enable      bool   `json:"enable" mapstructure:"enable"`
+	MaxSize     int64  `json:"max_size" mapstructure:"max_size"`       // MB, rotate to app.log.1, app.log.2... when exceeded, 0 means no limit
+	MaxBackups  int    `json:"max_backups" mapstructure:"max_backups"` // rotated files to keep, 0 means keep all
+	MaxAge      int    `json:"max_age" mapstructure:"max_age"`         // days to keep rotated files, 0 means keep forever
+	Compress    string `json:"compress" mapstructure:"compress"`       // gzip or zstd, compress the rotated files, empty means no compression
+	Pattern     string `json:"pattern" mapstructure:"pattern"`         // pattern layout, ex: "%d %-5p [%l] %m%n", empty means the default
+	Format      string `json:"format" mapstructure:"format"`           // text or json, pattern is ignored if json
 }
 
 // ConfConsoleWriter console writer config
 type ConfConsoleWriter struct {
-	Level  string `json:"level" mapstructure:"level"`
-	Enable bool   `json:"enable" mapstructure:"enable"`
-	Color  bool   `json:"color" mapstructure:"color"`
+	Level   string `json:"level" mapstructure:"level"` // empty means TRACE, the logger level filters
+	Enable  bool   `json:"enable" mapstructure:"enable"`
+	Color   bool   `json:"color" mapstructure:"color"`
+	Pattern string `json:"pattern" mapstructure:"pattern"` // pattern layout, the color is ignored if set
+	Format  string `json:"format" mapstructure:"format"`   // text or json, pattern and color are ignored if json
 }
 
-// KafKaMSGFields kafka msg fields
+// KafKaMSGFields kafka msg fields, the schema of the kafka messages: the init
+// fields are set in the config, the dynamic ones are written from the record
 type KafKaMSGFields struct {
 	ESIndex     string                 `json:"es_index" mapstructure:"es_index"`         // required, init field
 	Level       string                 `json:"level"`                                    // dynamic, set by logger
@@ -36,6 +49,9 @@ type KafKaMSGFields struct {
 	PublicIP    string                 `json:"public_ip" mapstructure:"public_ip"`       // required, init field, set by app
 	Timestamp   string                 `json:"timestamp" mapstructure:"timestamp"`       // required, dynamic, set by logger
 	Now         int64                  `json:"now" mapstructure:"now"`                   // choice, unix timestamp, second
+	Error       string                 `json:"error"`                                    // dynamic, set by Err, message of the error
+	ErrorChain  []string               `json:"error_chain"`                              // dynamic, set by Err, messages of the wrapped errors
+	ErrorType   string                 `json:"error_type"`                               // dynamic, set by Err, type of the innermost error
 	ExtraFields map[string]interface{} `json:"extra_fields" mapstructure:"extra_fields"` // extra fields will be added
 }
 
@@ -72,6 +88,31 @@ type ConfAliLogHubWriter struct {
 	BufSize         int    `json:"buf_size" mapstructure:"buf_size"`
 }
 
+// ConfRedactRule redaction rule config
+type ConfRedactRule struct {
+	Name    string `json:"name" mapstructure:"name"`
+	Pattern string `json:"pattern" mapstructure:"pattern"` // regexp, the first group is masked if it has one
+	Mask    string `json:"mask" mapstructure:"mask"`       // full, partial or hash, default the mask of the redaction
+	Numeric bool   `json:"numeric" mapstructure:"numeric"` // also mask the numeric field values, only the digit detectors do by default
+}
+
+// ConfRedact redaction config
+type ConfRedact struct {
+	Enable    bool             `json:"enable" mapstructure:"enable"`
+	Detectors []string         `json:"detectors" mapstructure:"detectors"` // bearer, email, cn_id, credit_card, cn_mobile, empty means all
+	Mask      string           `json:"mask" mapstructure:"mask"`           // full, partial (keep last 4) or hash, default full
+	Rules     []ConfRedactRule `json:"rules" mapstructure:"rules"`         // applied after the detectors
+}
+
+// ConfLogger named logger config, see GetLogger
+type ConfLogger struct {
+	Name          string            `json:"name" mapstructure:"name"`             // dot-separated, ex: com.team.payment
+	Level         string            `json:"level" mapstructure:"level"`           // empty means the level of the parent
+	Additivity    *bool             `json:"additivity" mapstructure:"additivity"` // also write to the writers of the parent, default true
+	FileWriter    ConfFileWriter    `json:"file_writer" mapstructure:"file_writer"`
+	ConsoleWriter ConfConsoleWriter `json:"console_writer" mapstructure:"console_writer"`
+}
+
 // LogConfig log config
 type LogConfig struct {
 	Level           string              `json:"level" mapstructure:"level"`
@@ -80,54 +121,341 @@ type LogConfig struct {
 	ConsoleWriter   ConfConsoleWriter   `json:"console_writer" mapstructure:"console_writer"`
 	AliLogHubWriter ConfAliLogHubWriter `json:"ali_log_hub_writer" mapstructure:"ali_log_hub_writer"`
 	KafKaWriter     ConfKafKaWriter     `json:"kafka_writer" mapstructure:"kafka_writer"`
+	TunnelSize      int                 `json:"tunnel_size" mapstructure:"tunnel_size"`           // records buffered before the writers, default 1024, applied before the first record
+	OverflowPolicy  string              `json:"overflow_policy" mapstructure:"overflow_policy"`   // block, drop_newest, drop_oldest or drop_below, default block
+	OverflowLevel   string              `json:"overflow_level" mapstructure:"overflow_level"`     // drop_below drops the records below it
+	AsyncQueueSize  int                 `json:"async_queue_size" mapstructure:"async_queue_size"` // if > 0, every writer gets its own queue and goroutine
+	AsyncOverflow   string              `json:"async_overflow" mapstructure:"async_overflow"`     // overflow policy of the writer queues, default block
+	StackLevel      string              `json:"stack_level" mapstructure:"stack_level"`           // records at this level or above get the stack, default none
+	StackDepth      int                 `json:"stack_depth" mapstructure:"stack_depth"`           // max frames of the stack, default 32
+	StackSkip       []string            `json:"stack_skip" mapstructure:"stack_skip"`             // packages left out of the stack, default runtime
+	SyncMode        bool                `json:"sync_mode" mapstructure:"sync_mode"`               // write the records in the log calls, not on the writer goroutine
+	SyncFlushLevel  string              `json:"sync_flush_level" mapstructure:"sync_flush_level"` // sync mode flushes the writers after a record at this level or above
+	DedupWindow     int                 `json:"dedup_window" mapstructure:"dedup_window"`         // ms, collapse the repeated records within it, 0 means disabled
+	Redact          ConfRedact          `json:"redact" mapstructure:"redact"`                     // mask the sensitive values before the writers
+	Loggers         []ConfLogger        `json:"loggers" mapstructure:"loggers"`                   // levels and writers of the named loggers
 }
 
-// SetupLog setup log
+// SetupLog setup log, calling it again applies the differences with the
+// running config: the levels, the added, removed or changed writers.
+// An invalid config is rejected and the running config is kept.
 func SetupLog(lc LogConfig) (err error) {
+	running.Lock()
+	defer running.Unlock()
+
+	if err = validateLogConfig(&lc); err != nil {
+		return err
+	}
+	redactor, err := NewRedactorWithConf(&lc.Redact)
+	if err != nil {
+		return err
+	}
+
+	if lc.AliLogHubWriter.Enable && lc.AliLogHubWriter.Source == "" {
+		lc.AliLogHubWriter.Source = util.GetLocalIpByTcp()
+	}
+
 	// global level
-	GlobalLevel = getLevel(lc.Level)
+	globalLevel := getLevel(lc.Level)
+	setups := writerSetups(&lc)
+
+	// create the new and changed writers first, nothing is applied if one fails
+	created := make(map[string]Writer, len(setups))
+	for _, ws := range setups {
+		old := running.setups[ws.name]
+		if !ws.enable || (old.enable && ws.sameWriter(old)) {
+			continue
+		}
+		w := ws.create()
+		if lc.AsyncQueueSize > 0 {
+			p, _ := ParseOverflowPolicy(lc.AsyncOverflow)
+			w = NewAsyncWriter(w, lc.AsyncQueueSize, p, getLevel(lc.OverflowLevel))
+		}
+		if err = w.Init(); err != nil {
+			for _, c := range created {
+				closeWriter(c)
+			}
+			return fmt.Errorf("init %s writer: %v", ws.name, err)
+		}
+		created[ws.name] = w
+	}
+
+	GlobalLevel = globalLevel
+	if globalLevel > -1 {
+		SetLevel(globalLevel)
+	} else {
+		SetLevel(DEBUG)
+	}
+
+	if lc.TunnelSize > 0 {
+		if err := loggerDefault.SetTunnelSize(lc.TunnelSize); err != nil {
+			logPrintf("log4go tunnel_size %d is not applied: %v\n", lc.TunnelSize, err)
+		}
+	}
+	p, _ := ParseOverflowPolicy(lc.OverflowPolicy)
+	SetOverflowPolicy(p, getLevel(lc.OverflowLevel))
 
 	fullPath := lc.FullPath
 	ShowFullPath(fullPath)
 
-	if lc.FileWriter.Enable { // file
-		if selfLevel := getLevel(lc.FileWriter.Level); selfLevel > -1 {
-			Register(NewFileWriter(&lc.FileWriter))
-		} else {
-			Register(NewFileWriterWithLevel(GlobalLevel, &lc.FileWriter))
+	stackLevel := getLevel(lc.StackLevel)
+	if stackLevel < 0 {
+		stackLevel = stackOff
+	}
+	SetStackTrace(stackLevel, lc.StackDepth, lc.StackSkip...)
+
+	SetSyncMode(lc.SyncMode, getLevel(lc.SyncFlushLevel))
+	SetDedup(time.Duration(lc.DedupWindow) * time.Millisecond)
+	// keep the redactor and its hit counters if unchanged
+	if !reflect.DeepEqual(lc.Redact, running.config.Redact) {
+		SetRedactor(redactor)
+	}
+
+	setupLoggers(lc.Loggers, running.config.Loggers)
+
+	writers := make(map[string]Writer, len(setups))
+	for _, ws := range setups {
+		owner := loggerDefault.GetLogger(ws.logger)
+		old := running.writers[ws.name]
+		if w, ok := created[ws.name]; ok {
+			owner.swapWriter(old, w)
+			writers[ws.name] = w
+		} else if ws.enable {
+			// level only
+			if lw, ok := old.(Leveler); ok && lw.Level() != ws.level {
+				lw.SetLevel(ws.level)
+			}
+			writers[ws.name] = old
+		} else if old != nil {
+			owner.swapWriter(old, nil)
+		}
+		if old != nil && writers[ws.name] != old {
+			closeWriter(old)
+		}
+	}
+	// the writers of the named loggers removed from the config
+	for name, old := range running.writers {
+		if _, ok := setups[name]; !ok {
+			loggerDefault.GetLogger(running.setups[name].logger).swapWriter(old, nil)
+			closeWriter(old)
 		}
 	}
 
-	if lc.ConsoleWriter.Enable { // Console
-		if selfLevel := getLevel(lc.FileWriter.Level); selfLevel > -1 {
-			Register(NewConsoleWriter(&lc.ConsoleWriter))
+	running.config = lc
+	running.setups = setups
+	running.writers = writers
+	return nil
+}
+
+// running config applied by SetupLog
+var running struct {
+	sync.Mutex
+	config  LogConfig
+	setups  map[string]writerSetup
+	writers map[string]Writer
+}
+
+// writerSetup a writer described by the config
+type writerSetup struct {
+	name   string
+	logger string // the named logger the writer is registered on, empty for the root
+	enable bool
+	level  int         // the writer level, TRACE if not set
+	conf   interface{} // the writer config without the level
+	async  string      // the async wrapper config
+	create func() Writer
+}
+
+// sameWriter report whether the writer is unchanged, except the level
+func (ws writerSetup) sameWriter(old writerSetup) bool {
+	return ws.async == old.async && reflect.DeepEqual(ws.conf, old.conf)
+}
+
+// writerLevel the level of a configured writer, TRACE if not set: the level of
+// the logger filters, so the records of a named logger at a lower level than
+// the root still reach the root writers
+func writerLevel(flag string) int {
+	if level := getLevel(flag); level > -1 {
+		return level
+	}
+	return TRACE
+}
+
+func writerSetups(lc *LogConfig) map[string]writerSetup {
+	async := ""
+	if lc.AsyncQueueSize > 0 {
+		async = fmt.Sprintf("%d/%s/%s", lc.AsyncQueueSize, lc.AsyncOverflow, lc.OverflowLevel)
+	}
+
+	file := lc.FileWriter
+	fileLevel := writerLevel(file.Level)
+	file.Level = ""
+	console := lc.ConsoleWriter
+	consoleLevel := writerLevel(console.Level)
+	console.Level = ""
+	aliLogHub := lc.AliLogHubWriter
+	aliLogHubLevel := writerLevel(aliLogHub.Level)
+	aliLogHub.Level = ""
+	kafka := lc.KafKaWriter
+	kafkaLevel := writerLevel(kafka.Level)
+	kafka.Level = ""
+
+	setups := map[string]writerSetup{
+		"file": {name: "file", enable: file.Enable, level: fileLevel, conf: file, async: async,
+			create: func() Writer { return NewFileWriterWithLevel(fileLevel, &file) }},
+		"console": {name: "console", enable: console.Enable, level: consoleLevel, conf: console, async: async,
+			create: func() Writer { return NewConsoleWriterWithLevel(consoleLevel, &console) }},
+		"ali_log_hub": {name: "ali_log_hub", enable: aliLogHub.Enable, level: aliLogHubLevel, conf: aliLogHub, async: async,
+			create: func() Writer { return NewAliLogHubWriterWithLevel(aliLogHubLevel, &aliLogHub) }},
+		"kafka": {name: "kafka", enable: kafka.Enable, level: kafkaLevel, conf: kafka, async: async,
+			create: func() Writer { return NewKafKaWriterWithWriter(kafkaLevel, &kafka) }},
+	}
+	for _, lgc := range lc.Loggers {
+		loggerWriterSetups(setups, lgc, async)
+	}
+	return setups
+}
+
+// loggerWriterSetups add the writers of the named logger, named <logger>/file
+// and <logger>/console
+func loggerWriterSetups(setups map[string]writerSetup, lgc ConfLogger, async string) {
+	name := loggerName(lgc.Name)
+
+	file := lgc.FileWriter
+	fileLevel := writerLevel(file.Level)
+	file.Level = ""
+	console := lgc.ConsoleWriter
+	consoleLevel := writerLevel(console.Level)
+	console.Level = ""
+
+	setups[name+"/file"] = writerSetup{name: name + "/file", logger: name, enable: file.Enable, level: fileLevel,
+		conf: file, async: async, create: func() Writer { return NewFileWriterWithLevel(fileLevel, &file) }}
+	setups[name+"/console"] = writerSetup{name: name + "/console", logger: name, enable: console.Enable, level: consoleLevel,
+		conf: console, async: async, create: func() Writer { return NewConsoleWriterWithLevel(consoleLevel, &console) }}
+}
+
+// setupLoggers set the level and additivity of the named loggers, those removed
+// from the config inherit the level again and are additive
+func setupLoggers(loggers, old []ConfLogger) {
+	configured := make(map[string]bool, len(loggers))
+	for _, lgc := range loggers {
+		name := loggerName(lgc.Name)
+		configured[name] = true
+		l := GetLogger(name)
+		if level := getLevel(lgc.Level); level > -1 {
+			l.SetLevel(level)
 		} else {
-			Register(NewConsoleWriterWithLevel(GlobalLevel, &lc.ConsoleWriter))
+			l.ResetLevel()
+		}
+		l.SetAdditivity(lgc.Additivity == nil || *lgc.Additivity)
+	}
+	for _, lgc := range old {
+		if name := loggerName(lgc.Name); !configured[name] {
+			l := GetLogger(name)
+			l.ResetLevel()
+			l.SetAdditivity(true)
 		}
 	}
+}
 
-	if lc.AliLogHubWriter.Enable { // Ali Loghub
-		if lc.AliLogHubWriter.Source == "" {
-			lc.AliLogHubWriter.Source = util.GetLocalIpByTcp()
+// validateLogConfig check the config before anything is applied
+func validateLogConfig(lc *LogConfig) error {
+	levels := map[string]string{
+		"level":                    lc.Level,
+		"overflow_level":           lc.OverflowLevel,
+		"stack_level":              lc.StackLevel,
+		"sync_flush_level":         lc.SyncFlushLevel,
+		"file_writer.level":        lc.FileWriter.Level,
+		"console_writer.level":     lc.ConsoleWriter.Level,
+		"ali_log_hub_writer.level": lc.AliLogHubWriter.Level,
+		"kafka_writer.level":       lc.KafKaWriter.Level,
+	}
+	for key, flag := range levels {
+		if strings.TrimSpace(flag) != "" && getLevel(flag) < 0 {
+			return errors.New("invalid " + key + " (" + flag + ")")
 		}
-		if selfLevel := getLevel(lc.AliLogHubWriter.Level); selfLevel > -1 {
-			Register(NewAliLogHubWriter(&lc.AliLogHubWriter))
-		} else {
-			Register(NewAliLogHubWriterWithLevel(GlobalLevel, &lc.AliLogHubWriter))
+	}
+	if lc.TunnelSize < 0 {
+		return fmt.Errorf("invalid tunnel_size (%d)", lc.TunnelSize)
+	}
+	if _, ok := ParseOverflowPolicy(lc.OverflowPolicy); !ok && lc.OverflowPolicy != "" {
+		return errors.New("invalid overflow policy (" + lc.OverflowPolicy + ")")
+	}
+	if _, ok := ParseOverflowPolicy(lc.AsyncOverflow); !ok && lc.AsyncOverflow != "" {
+		return errors.New("invalid async overflow policy (" + lc.AsyncOverflow + ")")
+	}
+	if err := validateWriters("", &lc.FileWriter, &lc.ConsoleWriter); err != nil {
+		return err
+	}
+
+	names := make(map[string]bool, len(lc.Loggers))
+	for i, lgc := range lc.Loggers {
+		name := loggerName(lgc.Name)
+		if name == "" {
+			return fmt.Errorf("loggers[%d].name is empty", i)
+		}
+		if strings.Contains(strings.Trim(strings.TrimSpace(lgc.Name), "."), "..") {
+			return errors.New("logger " + lgc.Name + " has an empty name part")
+		}
+		if names[name] {
+			return errors.New("logger " + name + " is configured twice")
+		}
+		names[name] = true
+		levels := map[string]string{
+			"level":                lgc.Level,
+			"file_writer.level":    lgc.FileWriter.Level,
+			"console_writer.level": lgc.ConsoleWriter.Level,
+		}
+		for key, flag := range levels {
+			if strings.TrimSpace(flag) != "" && getLevel(flag) < 0 {
+				return errors.New("invalid logger " + name + " " + key + " (" + flag + ")")
+			}
+		}
+		if err := validateWriters("logger "+name+" ", &lgc.FileWriter, &lgc.ConsoleWriter); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	if lc.KafKaWriter.Enable { // kafka
-		if selfLevel := getLevel(lc.KafKaWriter.Level); selfLevel > -1 {
-			Register(NewKafKaWriter(&lc.KafKaWriter))
-		} else {
-			Register(NewKafKaWriterWithWriter(GlobalLevel, &lc.KafKaWriter))
+// validateWriters check the file and console writer configs, prefix is the
+// start of the error messages
+func validateWriters(prefix string, file *ConfFileWriter, console *ConfConsoleWriter) error {
+	if file.Enable {
+		if file.PathPattern == "" {
+			return errors.New(prefix + "file_writer.path_pattern is empty")
+		}
+		if file.Compress != "" {
+			if _, err := newFileCompressor(file.Compress); err != nil {
+				return err
+			}
+		}
+		if _, err := newFormatter(file.Format, file.Pattern); err != nil {
+			return err
+		}
+	}
+	if console.Enable {
+		if _, err := newFormatter(console.Format, console.Pattern); err != nil {
+			return err
 		}
 	}
-	// 全局配置
 	return nil
 }
 
+// loggerName the name of a named logger in the config, like GetLogger reads
+// it: the empty parts are dropped, " a..b. " is "a.b"
+func loggerName(name string) string {
+	parts := strings.Split(strings.TrimSpace(name), ".")
+	i := 0
+	for _, part := range parts {
+		if part != "" {
+			parts[i] = part
+			i++
+		}
+	}
+	return strings.Join(parts[:i], ".")
+}
+
 // SetupLogWithConf setup log with config file
 func SetupLogWithConf(file string) (err error) {
 	var lc LogConfig
@@ -0,0 +1,201 @@
+package log4go
+
+import (
+	"context"
+)
+
+// well known field keys extracted from the context
+const (
+	TraceIDKey = "trace_id"
+	SpanIDKey  = "span_id"
+	TenantKey  = "tenant"
+)
+
+type contextKey int
+
+const (
+	traceContextKey contextKey = iota
+	fieldsContextKey
+)
+
+type traceContext struct {
+	traceID string
+	spanID  string
+}
+
+// ContextExtractor extract fields from the context, the fields are attached
+// to the record. An OpenTelemetry extractor looks like:
+//
+//	func(ctx context.Context) []log4go.Field {
+//		sc := trace.SpanContextFromContext(ctx)
+//		if !sc.IsValid() {
+//			return nil
+//		}
+//		return []log4go.Field{
+//			log4go.F(log4go.TraceIDKey, sc.TraceID().String()),
+//			log4go.F(log4go.SpanIDKey, sc.SpanID().String()),
+//		}
+//	}
+type ContextExtractor func(ctx context.Context) []Field
+
+// ContextWithTrace return a copy of ctx which carries the trace and span id
+func ContextWithTrace(ctx context.Context, traceID, spanID string) context.Context {
+	return context.WithValue(ctx, traceContextKey, traceContext{traceID: traceID, spanID: spanID})
+}
+
+// ContextWithFields return a copy of ctx which carries the key/value pairs,
+// pairs already in ctx are kept
+func ContextWithFields(ctx context.Context, kv ...interface{}) context.Context {
+	parent, _ := ctx.Value(fieldsContextKey).([]Field)
+	fields := make([]Field, 0, len(parent)+len(kv)/2)
+	fields = append(fields, parent...)
+	return context.WithValue(ctx, fieldsContextKey, fieldsFromKV(fields, kv))
+}
+
+// DefaultContextExtractor extract the values set by ContextWithTrace and ContextWithFields
+func DefaultContextExtractor(ctx context.Context) []Field {
+	var fields []Field
+	if tc, ok := ctx.Value(traceContextKey).(traceContext); ok {
+		if tc.traceID != "" {
+			fields = append(fields, Field{Key: TraceIDKey, Value: tc.traceID})
+		}
+		if tc.spanID != "" {
+			fields = append(fields, Field{Key: SpanIDKey, Value: tc.spanID})
+		}
+	}
+	if kv, ok := ctx.Value(fieldsContextKey).([]Field); ok {
+		fields = append(fields, kv...)
+	}
+	return fields
+}
+
+// ContextValueExtractor create an extractor which stores ctx.Value(ctxKey) under key,
+// ex: ContextValueExtractor(TenantKey, tenantCtxKey{})
+func ContextValueExtractor(key string, ctxKey interface{}) ContextExtractor {
+	return func(ctx context.Context) []Field {
+		if v := ctx.Value(ctxKey); v != nil {
+			return []Field{{Key: key, Value: v}}
+		}
+		return nil
+	}
+}
+
+// AddContextExtractor Logger add a context extractor used by the Ctx methods
+func (l *Logger) AddContextExtractor(e ContextExtractor) {
+	l.lock.Lock()
+	extractors := make([]ContextExtractor, 0, len(l.extractors)+1)
+	l.extractors = append(append(extractors, l.extractors...), e)
+	l.lock.Unlock()
+}
+
+// SetContextExtractors Logger replace the context extractors, none disables extraction
+func (l *Logger) SetContextExtractors(e ...ContextExtractor) {
+	l.lock.Lock()
+	l.extractors = e
+	l.lock.Unlock()
+}
+
+// WithContext Logger create a child logger with the fields extracted from ctx
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	fields := make([]Field, 0, len(l.fields)+2)
+	fields = append(fields, l.fields...)
+	return &Logger{loggerCore: l.loggerCore, fields: l.extractContext(ctx, fields), node: l.node}
+}
+
+func (l *Logger) extractContext(ctx context.Context, fields []Field) []Field {
+	if ctx == nil {
+		return fields
+	}
+	l.lock.RLock()
+	extractors := l.extractors
+	l.lock.RUnlock()
+	for _, e := range extractors {
+		fields = append(fields, e(ctx)...)
+	}
+	return fields
+}
+
+// TraceCtx Logger deliver record with the context values to writer
+func (l *Logger) TraceCtx(ctx context.Context, fmt string, args ...interface{}) {
+	l.deliverCtxToWriter(ctx, TRACE, fmt, args...)
+}
+
+// DebugCtx Logger deliver record with the context values to writer
+func (l *Logger) DebugCtx(ctx context.Context, fmt string, args ...interface{}) {
+	l.deliverCtxToWriter(ctx, DEBUG, fmt, args...)
+}
+
+// InfoCtx Logger deliver record with the context values to writer
+func (l *Logger) InfoCtx(ctx context.Context, fmt string, args ...interface{}) {
+	l.deliverCtxToWriter(ctx, INFO, fmt, args...)
+}
+
+// WarnCtx Logger deliver record with the context values to writer
+func (l *Logger) WarnCtx(ctx context.Context, fmt string, args ...interface{}) {
+	l.deliverCtxToWriter(ctx, WARNING, fmt, args...)
+}
+
+// ErrorCtx Logger deliver record with the context values to writer
+func (l *Logger) ErrorCtx(ctx context.Context, fmt string, args ...interface{}) {
+	l.deliverCtxToWriter(ctx, ERROR, fmt, args...)
+}
+
+// PanicCtx Logger deliver record with the context values to writer, flush then panic
+func (l *Logger) PanicCtx(ctx context.Context, fmt string, args ...interface{}) {
+	msg := formatMessage(fmt, args)
+	l.deliverCtxToWriter(ctx, PANIC, "%s", msg)
+	l.Flush()
+	panic(msg)
+}
+
+// FatalCtx Logger deliver record with the context values to writer, close the logger then exit
+func (l *Logger) FatalCtx(ctx context.Context, fmt string, args ...interface{}) {
+	deadline, cancel := l.fatalDeadline()
+	l.deliverCtxToWriter(ctx, FATAL, fmt, args...)
+	l.exit(deadline, cancel)
+}
+
+// TraceCtx loggerDefault deliver record with the context values to writer
+func TraceCtx(ctx context.Context, fmt string, args ...interface{}) {
+	loggerDefault.deliverCtxToWriter(ctx, TRACE, fmt, args...)
+}
+
+// DebugCtx loggerDefault deliver record with the context values to writer
+func DebugCtx(ctx context.Context, fmt string, args ...interface{}) {
+	loggerDefault.deliverCtxToWriter(ctx, DEBUG, fmt, args...)
+}
+
+// InfoCtx loggerDefault deliver record with the context values to writer
+func InfoCtx(ctx context.Context, fmt string, args ...interface{}) {
+	loggerDefault.deliverCtxToWriter(ctx, INFO, fmt, args...)
+}
+
+// WarnCtx loggerDefault deliver record with the context values to writer
+func WarnCtx(ctx context.Context, fmt string, args ...interface{}) {
+	loggerDefault.deliverCtxToWriter(ctx, WARNING, fmt, args...)
+}
+
+// ErrorCtx loggerDefault deliver record with the context values to writer
+func ErrorCtx(ctx context.Context, fmt string, args ...interface{}) {
+	loggerDefault.deliverCtxToWriter(ctx, ERROR, fmt, args...)
+}
+
+// PanicCtx loggerDefault deliver record with the context values to writer, flush then panic
+func PanicCtx(ctx context.Context, fmt string, args ...interface{}) {
+	msg := formatMessage(fmt, args)
+	loggerDefault.deliverCtxToWriter(ctx, PANIC, "%s", msg)
+	loggerDefault.Flush()
+	panic(msg)
+}
+
+// FatalCtx loggerDefault deliver record with the context values to writer, close the logger then exit
+func FatalCtx(ctx context.Context, fmt string, args ...interface{}) {
+	deadline, cancel := loggerDefault.fatalDeadline()
+	loggerDefault.deliverCtxToWriter(ctx, FATAL, fmt, args...)
+	loggerDefault.exit(deadline, cancel)
+}
+
+// AddContextExtractor loggerDefault add a context extractor
+func AddContextExtractor(e ContextExtractor) {
+	loggerDefault.AddContextExtractor(e)
+}
@@ -0,0 +1,91 @@
+package log4go
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+type tenantCtxKey struct{}
+
+func TestContextExtractors(t *testing.T) {
+	ctx := ContextWithTrace(context.Background(), "t1", "s1")
+	ctx = ContextWithFields(ctx, "uid", 7)
+	ctx = context.WithValue(ctx, tenantCtxKey{}, "acme")
+
+	tests := []struct {
+		name       string
+		extractors []ContextExtractor
+		ctx        context.Context
+		want       string
+	}{
+		{"default", []ContextExtractor{DefaultContextExtractor}, ctx, " trace_id=t1 span_id=s1 uid=7"},
+		{"value", []ContextExtractor{ContextValueExtractor(TenantKey, tenantCtxKey{})}, ctx, " tenant=acme"},
+		{"both", []ContextExtractor{DefaultContextExtractor, ContextValueExtractor(TenantKey, tenantCtxKey{})}, ctx,
+			" trace_id=t1 span_id=s1 uid=7 tenant=acme"},
+		{"none", nil, ctx, ""},
+		{"empty context", []ContextExtractor{DefaultContextExtractor, ContextValueExtractor(TenantKey, tenantCtxKey{})},
+			context.Background(), ""},
+		{"span only", []ContextExtractor{DefaultContextExtractor}, ContextWithTrace(context.Background(), "", "s2"), " span_id=s2"},
+		{"nested fields", []ContextExtractor{DefaultContextExtractor},
+			ContextWithFields(ContextWithFields(context.Background(), "a", 1), "b", 2), " a=1 b=2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, w := newTestLogger(t)
+			l.SetContextExtractors(tt.extractors...)
+			l.With("service", "api").InfoCtx(tt.ctx, "hi %d", 1)
+			l.Flush()
+			r := w.last()
+			if r.info != "hi 1" {
+				t.Fatalf("message = %q", r.info)
+			}
+			if got := fieldsString(r.fields); got != " service=api"+tt.want {
+				t.Fatalf("fields = %q, want %q", got, " service=api"+tt.want)
+			}
+			if !strings.HasPrefix(r.code, "context_test.go:") {
+				t.Fatalf("code = %s", r.code)
+			}
+		})
+	}
+}
+
+func TestContextMethods(t *testing.T) {
+	l, w := newTestLogger(t)
+	l.AddContextExtractor(ContextValueExtractor(TenantKey, tenantCtxKey{}))
+	ctx := context.WithValue(ContextWithTrace(context.Background(), "t1", "s1"), tenantCtxKey{}, "acme")
+	want := " trace_id=t1 span_id=s1 tenant=acme"
+
+	tests := []struct {
+		level int
+		log   func(ctx context.Context, fmt string, args ...interface{})
+	}{
+		{TRACE, l.TraceCtx},
+		{DEBUG, l.DebugCtx},
+		{INFO, l.InfoCtx},
+		{WARNING, l.WarnCtx},
+		{ERROR, l.ErrorCtx},
+	}
+	for _, tt := range tests {
+		tt.log(ctx, "%s", LevelFlags[tt.level])
+		l.Flush()
+		r := w.last()
+		if r.level != tt.level || r.info != LevelFlags[tt.level] {
+			t.Errorf("%s: record %s %s", LevelFlags[tt.level], LevelFlags[r.level], r.info)
+		}
+		if got := fieldsString(r.fields); got != want {
+			t.Errorf("%s: fields = %q, want %q", LevelFlags[tt.level], got, want)
+		}
+	}
+
+	l.WithContext(ctx).Info("with context")
+	l.Flush()
+	if r := w.last(); fieldsString(r.fields) != want {
+		t.Errorf("WithContext fields = %q, want %q", fieldsString(r.fields), want)
+	}
+	l.InfoCtx(nil, "nil context")
+	l.Flush()
+	if r := w.last(); r.info != "nil context" || len(r.fields) != 0 {
+		t.Errorf("nil context record %s %v", r.info, r.fields)
+	}
+}
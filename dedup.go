@@ -0,0 +1,99 @@
+package log4go
+
+import (
+	"fmt"
+	"time"
+)
+
+// dedupState the last written record and its suppressed repeats
+type dedupState struct {
+	window   time.Duration // 0 if disabled
+	last     *Record       // copy of the last written record, nil if none
+	repeated int
+	first    time.Time // time of the first and last suppressed repeat
+	lastAt   time.Time
+}
+
+// WithDedup collapse the repeated records, see SetDedup
+func WithDedup(window time.Duration) LoggerOption {
+	return func(l *Logger) {
+		l.dedup.window = window
+	}
+}
+
+// SetDedup collapse the consecutive records with the same level, code,
+// message and logger within window after the first one: the first one is written, the
+// repeats are counted and written once as a record annotated with
+// "repeated N times, first at X, last at Y", like syslogd does.
+// A window <= 0 disables it.
+func (l *Logger) SetDedup(window time.Duration) {
+	l.writeLock.Lock()
+	l.emitRepeated()
+	if window <= 0 && l.dedup.last != nil {
+		recordPool.Put(l.dedup.last)
+		l.dedup.last = nil
+	}
+	l.dedup.window = window
+	l.writeLock.Unlock()
+}
+
+// dedupRecord whether r is written, false if it repeats the last record,
+// the writeLock is held
+func (l *Logger) dedupRecord(r *Record) bool {
+	d := &l.dedup
+	if last := d.last; last != nil && r.level == last.level && r.code == last.code && r.info == last.info && r.node == last.node &&
+		r.ts.Sub(last.ts) < d.window {
+		if d.repeated == 0 {
+			d.first = r.ts
+		}
+		d.repeated++
+		d.lastAt = r.ts
+		return false
+	}
+
+	l.emitRepeated()
+	if d.last == nil {
+		d.last = recordPool.Get().(*Record)
+	}
+	d.last.copyFrom(r)
+	return true
+}
+
+// emitRepeated write the record of the suppressed repeats, if any, the writeLock is held
+func (l *Logger) emitRepeated() {
+	d := &l.dedup
+	if d.repeated == 0 {
+		return
+	}
+	l.lock.RLock()
+	layout := l.layout
+	l.lock.RUnlock()
+
+	last := d.last
+	info := fmt.Sprintf("%s (repeated %d times, first at %s, last at %s)",
+		last.info, d.repeated, d.first.Format(layout), d.lastAt.Format(layout))
+	r := l.newRecord(last.level, last.code, info)
+	r.pc = last.pc
+	r.node = last.node
+	r.fields = append(r.fields, last.fields...)
+	r.fields = append(r.fields, Field{Key: "repeated", Value: d.repeated})
+	d.repeated = 0
+
+	l.writeRecord(r)
+	recordPool.Put(r)
+}
+
+// flushDedup write the record of the suppressed repeats once the window
+// ended, or now if force
+func (l *Logger) flushDedup(force bool) {
+	l.writeLock.Lock()
+	if d := &l.dedup; d.repeated > 0 && (force || time.Since(d.last.ts) >= d.window) {
+		l.emitRepeated()
+	}
+	l.writeLock.Unlock()
+}
+
+// SetDedup loggerDefault collapse the repeated records
+func SetDedup(window time.Duration) {
+	loggerDefault.SetDedup(window)
+}
@@ -0,0 +1,108 @@
+package log4go
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDedupCollapsesRepeats(t *testing.T) {
+	l, w := newTestLogger(t, WithDedup(time.Minute))
+	// a single call site
+	info := func(msg string) { l.Info(msg) }
+	for i := 0; i < 4; i++ {
+		info("disk full")
+	}
+	info("disk ok")
+	l.Flush()
+
+	msgs := w.messages()
+	if len(msgs) != 3 || msgs[0] != "disk full" || msgs[2] != "disk ok" {
+		t.Fatalf("messages = %q", msgs)
+	}
+	if !strings.HasPrefix(msgs[1], "disk full (repeated 3 times, first at ") || !strings.Contains(msgs[1], ", last at ") {
+		t.Errorf("summary = %s", msgs[1])
+	}
+	fields := w.records[1].Fields()
+	if f := fields[len(fields)-1]; f.Key != "repeated" || f.Value != 3 {
+		t.Errorf("summary fields = %v", fields)
+	}
+	if w.records[1].code != w.records[0].code || w.records[1].level != INFO {
+		t.Errorf("summary code/level = %s/%d, want %s/%d", w.records[1].code, w.records[1].level, w.records[0].code, INFO)
+	}
+}
+
+func TestDedupDifferentSiteOrLevel(t *testing.T) {
+	l, w := newTestLogger(t, WithDedup(time.Minute))
+	l.Info("same")
+	l.Info("same")
+	leveled := func(level int) { l.output(nil, 1, level, "same", nil, nil) }
+	leveled(INFO)
+	leveled(WARNING)
+	named := l.GetLogger("a")
+	logNamed := func(lg *Logger) { lg.Info("named") }
+	logNamed(l)
+	logNamed(named)
+	l.Flush()
+
+	if got := len(w.messages()); got != 6 {
+		t.Fatalf("messages = %q, want 6 records, none collapsed", w.messages())
+	}
+	if w.records[2].code != w.records[3].code || w.records[4].code != w.records[5].code {
+		t.Error("not the same call site")
+	}
+}
+
+func TestDedupWindowExpired(t *testing.T) {
+	l, w := newTestLogger(t, WithDedup(20*time.Millisecond))
+	info := func() { l.Info("tick") }
+	info()
+	time.Sleep(30 * time.Millisecond)
+	info()
+	l.Flush()
+
+	if got := strings.Join(w.messages(), ","); got != "tick,tick" {
+		t.Fatalf("messages = %s", got)
+	}
+}
+
+func TestFlushDedup(t *testing.T) {
+	l, w := newTestLogger(t, WithDedup(time.Minute))
+	info := func() { l.Info("tick") }
+	for i := 0; i < 3; i++ {
+		info()
+	}
+	l.Flush()
+
+	l.flushDedup(false)
+	if got := len(w.messages()); got != 1 {
+		t.Fatalf("window not ended, %d records written", got)
+	}
+	l.flushDedup(true)
+	msgs := w.messages()
+	if len(msgs) != 2 || !strings.HasPrefix(msgs[1], "tick (repeated 2 times") {
+		t.Fatalf("messages = %q", msgs)
+	}
+	// nothing left to write
+	l.flushDedup(true)
+	if got := len(w.messages()); got != 2 {
+		t.Fatalf("%d records after the second flush", got)
+	}
+}
+
+func TestSetDedupDisable(t *testing.T) {
+	l, w := newTestLogger(t, WithDedup(time.Minute))
+	info := func() { l.Info("tick") }
+	info()
+	info()
+	l.Flush()
+	// the repeat is written when dedup is disabled
+	l.SetDedup(0)
+	info()
+	l.Flush()
+
+	msgs := w.messages()
+	if len(msgs) != 3 || !strings.HasPrefix(msgs[1], "tick (repeated 1 times") || msgs[2] != "tick" {
+		t.Fatalf("messages = %q", msgs)
+	}
+}
@@ -0,0 +1,97 @@
+package log4go
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// keys of the fields added by Err
+const (
+	ErrorKey      = "error"
+	ErrorChainKey = "error_chain"
+	ErrorTypeKey  = "error_type"
+)
+
+// errorChain messages of the wrapped errors, outermost first. Text output
+// joins them with " <- ", JSON output is an array.
+type errorChain []string
+
+func (c errorChain) String() string {
+	return strings.Join(c, " <- ")
+}
+
+func (c errorChain) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]string(c))
+}
+
+// errorFields append the error, error_chain and error_type fields of err,
+// error_type is the type of the innermost error
+func errorFields(fields []Field, err error) []Field {
+	var chain errorChain
+	root := err
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		chain = append(chain, e.Error())
+		root = e
+	}
+	return append(fields,
+		Field{Key: ErrorKey, Value: err.Error()},
+		Field{Key: ErrorChainKey, Value: chain},
+		Field{Key: ErrorTypeKey, Value: fmt.Sprintf("%T", root)},
+	)
+}
+
+// errorStack program counters of the stack carried by the innermost error that
+// has one. Supported are a Callers() []uintptr method and a StackTrace()
+// method returning a slice of uintptr kind, like github.com/pkg/errors.
+func errorStack(err error) []uintptr {
+	var pcs []uintptr
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if s := stackOf(e); len(s) > 0 {
+			pcs = s
+		}
+	}
+	return pcs
+}
+
+func stackOf(err error) []uintptr {
+	if c, ok := err.(interface{ Callers() []uintptr }); ok {
+		return c.Callers()
+	}
+	m := reflect.ValueOf(err).MethodByName("StackTrace")
+	if !m.IsValid() {
+		return nil
+	}
+	t := m.Type()
+	if t.NumIn() != 0 || t.NumOut() != 1 || t.Out(0).Kind() != reflect.Slice || t.Out(0).Elem().Kind() != reflect.Uintptr {
+		return nil
+	}
+	st := m.Call(nil)[0]
+	pcs := make([]uintptr, st.Len())
+	for i := range pcs {
+		pcs[i] = uintptr(st.Index(i).Uint())
+	}
+	return pcs
+}
+
+// Err Logger deliver an ERROR record of err with key/value pairs to writer.
+// The record gets the error, error_chain and error_type fields, and the stack
+// of the error if it carries one.
+func (l *Logger) Err(err error, msg string, kv ...interface{}) {
+	l.deliverErrToWriter(err, msg, kv)
+}
+
+// deliverErrToWriter like deliverFieldsToWriter, with the error
+func (l *Logger) deliverErrToWriter(err error, msg string, kv []interface{}) {
+	if !l.Enabled(ERROR) {
+		return
+	}
+	l.output(nil, 3, ERROR, msg, kv, err)
+}
+
+// Err loggerDefault deliver an ERROR record of err with key/value pairs to writer
+func Err(err error, msg string, kv ...interface{}) {
+	loggerDefault.deliverErrToWriter(err, msg, kv)
+}
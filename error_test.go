@@ -0,0 +1,42 @@
+package log4go
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestErrorFieldsInKafkaMessage(t *testing.T) {
+	err := fmt.Errorf("read config: %w", io.ErrUnexpectedEOF)
+	r := &Record{level: ERROR, code: "main.go:1", info: "setup failed"}
+	r.fields = errorFields(r.fields, err)
+
+	var buf bytes.Buffer
+	newKafKaFormatter(&KafKaMSGFields{ESIndex: "idx"}).encode(&buf, r)
+	var msg map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &msg); err != nil {
+		t.Fatalf("%v: %s", err, buf.String())
+	}
+
+	if got := msg[ErrorKey]; got != "read config: unexpected EOF" {
+		t.Errorf("%s = %v", ErrorKey, got)
+	}
+	if got := fmt.Sprint(msg[ErrorChainKey]); got != "[read config: unexpected EOF unexpected EOF]" {
+		t.Errorf("%s = %v", ErrorChainKey, got)
+	}
+	if got := msg[ErrorTypeKey]; got != "*errors.errorString" {
+		t.Errorf("%s = %v", ErrorTypeKey, got)
+	}
+
+	// the schema struct reads the message back
+	var fields KafKaMSGFields
+	if err := json.Unmarshal(buf.Bytes(), &fields); err != nil {
+		t.Fatal(err)
+	}
+	if fields.Error != "read config: unexpected EOF" || len(fields.ErrorChain) != 2 || fields.ErrorType != "*errors.errorString" ||
+		fields.Message != "setup failed" || fields.ESIndex != "idx" {
+		t.Errorf("fields = %+v", fields)
+	}
+}
@@ -11,7 +11,6 @@ import (
 	"github.com/spf13/viper"
 	"log"
 	"os"
-	"time"
 )
 
 // SetLog set logger
@@ -39,5 +38,5 @@ func main() {
 	log4go.Info("console Writer for log4go")
 	log4go.Debug("console Writer for log4go")
 	log4go.Error("console Writer for log4go")
-	time.Sleep(2 * time.Second)
+	log4go.Close()
 }
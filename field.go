@@ -0,0 +1,102 @@
+package log4go
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const badKey = "!BADKEY"
+
+// Field key/value pair attached to a record
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F create a field
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// String field string, k=v
+func (f Field) String() string {
+	return f.Key + "=" + quoteFieldValue(formatFieldValue(f.Value))
+}
+
+// fieldsFromKV convert key/value pairs to fields, Field values are taken as is,
+// a key without value or a non string key is stored under badKey
+func fieldsFromKV(dst []Field, kv []interface{}) []Field {
+	for i := 0; i < len(kv); i++ {
+		switch k := kv[i].(type) {
+		case Field:
+			dst = append(dst, k)
+		case string:
+			if i+1 < len(kv) {
+				dst = append(dst, Field{Key: k, Value: kv[i+1]})
+				i++
+			} else {
+				dst = append(dst, Field{Key: badKey, Value: k})
+			}
+		default:
+			dst = append(dst, Field{Key: badKey, Value: k})
+		}
+	}
+	return dst
+}
+
+// formatFieldValue format field value as text
+func formatFieldValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return "<nil>"
+	case string:
+		return val
+	case error:
+		return val.Error()
+	case fmt.Stringer:
+		return val.String()
+	case []byte:
+		return string(val)
+	case int:
+		return strconv.Itoa(val)
+	case int64:
+		return strconv.FormatInt(val, 10)
+	case bool:
+		return strconv.FormatBool(val)
+	}
+	return fmt.Sprint(v)
+}
+
+func quoteFieldValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " =\"\t\r\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// writeFields write fields as " k=v" pairs
+func writeFields(buf *bytes.Buffer, fields []Field) {
+	for _, f := range fields {
+		buf.WriteByte(' ')
+		writeField(buf, f)
+	}
+}
+
+// writeField write field as k=v
+func writeField(buf *bytes.Buffer, f Field) {
+	buf.WriteString(f.Key)
+	buf.WriteByte('=')
+	buf.WriteString(quoteFieldValue(formatFieldValue(f.Value)))
+}
+
+// fieldsString fields text, with a leading space
+func fieldsString(fields []Field) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	var buf bytes.Buffer
+	writeFields(&buf, fields)
+	return buf.String()
+}
@@ -0,0 +1,92 @@
+package log4go
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFieldsFromKV(t *testing.T) {
+	tests := []struct {
+		name string
+		kv   []interface{}
+		want []Field
+	}{
+		{"empty", nil, nil},
+		{"pairs", []interface{}{"uid", 7, "name", "bob"}, []Field{{"uid", 7}, {"name", "bob"}}},
+		{"field", []interface{}{F("uid", 7), "name", "bob"}, []Field{{"uid", 7}, {"name", "bob"}}},
+		{"missing value", []interface{}{"uid", 7, "name"}, []Field{{"uid", 7}, {badKey, "name"}}},
+		{"non string key", []interface{}{42, "uid", 7}, []Field{{badKey, 42}, {"uid", 7}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fieldsFromKV(nil, tt.kv); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("fields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldString(t *testing.T) {
+	tests := []struct {
+		field Field
+		want  string
+	}{
+		{F("uid", 7), "uid=7"},
+		{F("id", int64(-3)), "id=-3"},
+		{F("ok", true), "ok=true"},
+		{F("name", "bob"), "name=bob"},
+		{F("name", "bob smith"), `name="bob smith"`},
+		{F("eq", "a=b"), `eq="a=b"`},
+		{F("quote", `say "hi"`), `quote="say \"hi\""`},
+		{F("line", "a\nb"), `line="a\nb"`},
+		{F("empty", ""), `empty=""`},
+		{F("nil", nil), "nil=<nil>"},
+		{F("err", errors.New("no such file")), `err="no such file"`},
+		{F("bytes", []byte("raw")), "bytes=raw"},
+		{F("float", 1.5), "float=1.5"},
+	}
+	for _, tt := range tests {
+		if got := tt.field.String(); got != tt.want {
+			t.Errorf("%s: String = %s, want %s", tt.field.Key, got, tt.want)
+		}
+	}
+}
+
+func TestWithFields(t *testing.T) {
+	l, w := newTestLogger(t)
+	parent := l.With("service", "api")
+	child := parent.With("uid", 7)
+
+	tests := []struct {
+		name string
+		log  func()
+		want []Field
+	}{
+		{"logger", func() { l.Info("hi") }, nil},
+		{"parent", func() { parent.Info("hi") }, []Field{{"service", "api"}}},
+		{"child", func() { child.Info("hi") }, []Field{{"service", "api"}, {"uid", 7}}},
+		{"child infow", func() { child.Infow("hi", "latency", 12) }, []Field{{"service", "api"}, {"uid", 7}, {"latency", 12}}},
+		{"parent errorw", func() { parent.Errorw("hi", F("code", 500)) }, []Field{{"service", "api"}, {"code", 500}}},
+		{"parent after child", func() { parent.Warnw("hi") }, []Field{{"service", "api"}}},
+	}
+	for i, tt := range tests {
+		tt.log()
+		l.Flush()
+		if n := len(w.messages()); n != i+1 {
+			t.Fatalf("%s: %d records", tt.name, n)
+		}
+		if r := w.last(); fieldsString(r.fields) != fieldsString(tt.want) {
+			t.Errorf("%s: fields = %v, want %v", tt.name, r.fields, tt.want)
+		}
+	}
+}
+
+func TestRecordStringFields(t *testing.T) {
+	r := &Record{time: "2006/01/02 15:04:05", level: INFO, code: "main.go:12", info: "hi",
+		fields: []Field{F("uid", 7), F("name", "bob smith")}}
+	want := "2006/01/02 15:04:05 [INFO] <main.go:12> hi uid=7 name=\"bob smith\"\n"
+	if got := r.String(); got != want {
+		t.Fatalf("String = %q, want %q", got, want)
+	}
+}
@@ -5,6 +5,7 @@ go 1.14
 require (
 	github.com/Shopify/sarama v1.26.4
 	github.com/aliyun/aliyun-log-go-sdk v0.1.20
+	github.com/klauspost/compress v1.9.8
 	github.com/spf13/viper v1.7.0
 	google.golang.org/protobuf v1.25.0
 )
@@ -0,0 +1,128 @@
+package log4go
+
+import "sync/atomic"
+
+// loggerNode a named logger of the hierarchy, the logger core is the root
+type loggerNode struct {
+	name     string
+	parent   *loggerNode // nil if the parent is the root
+	level    int32       // -1 if inherited from the parent, accessed atomically
+	additive int32       // 1 if the records also go to the writers of the parent, accessed atomically
+	writers  []Writer    // guarded by writersLock
+	logger   *Logger
+}
+
+// GetLogger Logger get the named logger, created on first use. The names form
+// a dot-separated hierarchy like log4j: "com.team" is the parent of
+// "com.team.payment", the names are absolute and the root is the logger
+// created by NewLogger. The empty parts of the name are ignored, "a..b" is "a.b".
+// A named logger uses the level of its nearest ancestor with a level set, and
+// writes its records to its own writers then to those of its ancestors, up to
+// the root, unless the additivity of one of them is off.
+// Named loggers share the core of the root, the tunnel, hooks, sampler...
+func (l *Logger) GetLogger(name string) *Logger {
+	name = loggerName(name)
+	if name == "" {
+		return &Logger{loggerCore: l.loggerCore}
+	}
+
+	l.namesLock.Lock()
+	defer l.namesLock.Unlock()
+	if n, ok := l.names[name]; ok {
+		return n.logger
+	}
+	if l.names == nil {
+		l.names = make(map[string]*loggerNode)
+	}
+	// the ancestors are created first, so the parent of a node never changes
+	var parent *loggerNode
+	for i := 0; i <= len(name); i++ {
+		if i < len(name) && name[i] != '.' {
+			continue
+		}
+		prefix := name[:i]
+		n, ok := l.names[prefix]
+		if !ok {
+			n = &loggerNode{name: prefix, parent: parent, level: -1, additive: 1}
+			n.logger = &Logger{loggerCore: l.loggerCore, node: n}
+			l.names[prefix] = n
+			l.nodes = append(l.nodes, n)
+		}
+		parent = n
+	}
+	return parent.logger
+}
+
+// Name Logger name of the logger, empty for the root
+func (l *Logger) Name() string {
+	if l.node == nil {
+		return ""
+	}
+	return l.node.name
+}
+
+// SetAdditivity Logger whether the records also go to the writers of the
+// ancestors, default true. It has no effect on the root.
+func (l *Logger) SetAdditivity(additive bool) {
+	if l.node == nil {
+		return
+	}
+	var v int32
+	if additive {
+		v = 1
+	}
+	atomic.StoreInt32(&l.node.additive, v)
+}
+
+// Additivity Logger whether the records also go to the writers of the ancestors
+func (l *Logger) Additivity() bool {
+	return l.node == nil || atomic.LoadInt32(&l.node.additive) == 1
+}
+
+// ResetLevel Logger use the level of the parent again, no effect on the root
+func (l *Logger) ResetLevel() {
+	if l.node != nil {
+		atomic.StoreInt32(&l.node.level, -1)
+	}
+}
+
+// minLevel the level of the nearest ancestor with a level set, the root level if none
+func (l *Logger) minLevel() int32 {
+	for n := l.node; n != nil; n = n.parent {
+		if level := atomic.LoadInt32(&n.level); level >= 0 {
+			return level
+		}
+	}
+	return atomic.LoadInt32(&l.level)
+}
+
+// ownWriters the writers registered on the logger itself, the writersLock is held
+func (l *Logger) ownWriters() *[]Writer {
+	if l.node != nil {
+		return &l.node.writers
+	}
+	return &l.writers
+}
+
+// eachWriter call fn with the writers of the root then those of the named
+// loggers, the writersLock is held for reading
+func (l *Logger) eachWriter(fn func(Writer)) {
+	l.writersLock.RLock()
+	defer l.writersLock.RUnlock()
+	for _, w := range l.writers {
+		fn(w)
+	}
+	l.namesLock.Lock()
+	nodes := l.nodes
+	l.namesLock.Unlock()
+	for _, n := range nodes {
+		for _, w := range n.writers {
+			fn(w)
+		}
+	}
+}
+
+// GetLogger loggerDefault get the named logger, see Logger.GetLogger
+func GetLogger(name string) *Logger {
+	return loggerDefault.GetLogger(name)
+}
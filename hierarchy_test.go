@@ -0,0 +1,164 @@
+package log4go
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGetLoggerInheritsLevel(t *testing.T) {
+	l, _ := newTestLogger(t)
+	l.SetLevel(INFO)
+	team := l.GetLogger("com.team")
+	db := l.GetLogger("com.team.payment.db")
+
+	if db.Name() != "com.team.payment.db" || l.GetLogger("com.team.payment.db") != db {
+		t.Fatalf("GetLogger(com.team.payment.db) = %q, not the same logger", db.Name())
+	}
+	if db.Enabled(DEBUG) {
+		t.Error("db enabled at DEBUG, the root is INFO")
+	}
+	team.SetLevel(DEBUG)
+	if !db.Enabled(DEBUG) || db.Enabled(TRACE) {
+		t.Error("db does not inherit DEBUG from com.team")
+	}
+	db.SetLevel(ERROR)
+	if db.Enabled(WARNING) || !team.Enabled(DEBUG) {
+		t.Error("the level of db changed its parent")
+	}
+	db.ResetLevel()
+	if !db.Enabled(DEBUG) {
+		t.Error("db does not inherit after ResetLevel")
+	}
+	if root := l.GetLogger(""); root.Name() != "" || !root.Enabled(INFO) || root.Enabled(DEBUG) {
+		t.Error("GetLogger(\"\") is not the root")
+	}
+}
+
+func TestGetLoggerNormalizesName(t *testing.T) {
+	l, _ := newTestLogger(t)
+	ab := l.GetLogger("a.b")
+	for _, name := range []string{"a..b", ".a.b.", " a.b ", "a...b"} {
+		if got := l.GetLogger(name); got != ab {
+			t.Errorf("GetLogger(%q) = %q, want a.b", name, got.Name())
+		}
+	}
+	l.namesLock.Lock()
+	defer l.namesLock.Unlock()
+	if len(l.nodes) != 2 || l.nodes[0].name != "a" || ab.node.parent != l.nodes[0] {
+		t.Errorf("nodes = %d, want a and a.b", len(l.nodes))
+	}
+}
+
+func TestLoggerConfigNames(t *testing.T) {
+	for name, ok := range map[string]bool{
+		"com.team":   true,
+		" .com.team": true,
+		"com..team":  false,
+		"com.team..": true,
+		"..":         false,
+		"":           false,
+	} {
+		err := validateLogConfig(&LogConfig{Loggers: []ConfLogger{{Name: name}}})
+		if (err == nil) != ok {
+			t.Errorf("logger %q: err = %v", name, err)
+		}
+	}
+	err := validateLogConfig(&LogConfig{Loggers: []ConfLogger{{Name: "a.b"}, {Name: ".a.b"}}})
+	if err == nil {
+		t.Error("a.b configured twice accepted")
+	}
+}
+
+func TestGetLoggerWritersAndAdditivity(t *testing.T) {
+	l, root := newTestLogger(t)
+	payment := l.GetLogger("com.team.payment")
+	own := &memWriter{}
+	payment.Register(own)
+	db := l.GetLogger("com.team.payment.db")
+
+	db.Info("db")
+	payment.Info("payment")
+	l.Info("root")
+	l.Flush()
+	payment.SetAdditivity(false)
+	db.Info("db not additive")
+	l.Flush()
+
+	if got := strings.Join(own.messages(), ","); got != "db,payment,db not additive" {
+		t.Errorf("payment writer = %s", got)
+	}
+	if got := strings.Join(root.messages(), ","); got != "db,payment,root" {
+		t.Errorf("root writer = %s", got)
+	}
+	if name := own.records[0].LoggerName(); name != "com.team.payment.db" {
+		t.Errorf("logger name = %q", name)
+	}
+}
+
+func TestSetupLogNamedLoggerReachesRootWriters(t *testing.T) {
+	dir := tempDir(t)
+	p := filepath.Join(dir, "app.log")
+	t.Cleanup(func() { _ = SetupLog(LogConfig{}) })
+
+	err := SetupLog(LogConfig{
+		Level:      "INFO",
+		FileWriter: ConfFileWriter{Enable: true, PathPattern: p},
+		Loggers:    []ConfLogger{{Name: "com.team.payment", Level: "DEBUG"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	GetLogger("com.team.payment.db").Debug("named debug")
+	Debug("root debug")
+	Info("root info")
+	Flush()
+
+	content := readTestFile(t, p)
+	if !strings.Contains(content, "named debug") || !strings.Contains(content, "root info") {
+		t.Errorf("file = %q, want the DEBUG record of the named logger and the root INFO", content)
+	}
+	if strings.Contains(content, "root debug") {
+		t.Errorf("file = %q, the root is INFO", content)
+	}
+}
+
+func TestSetupLogWriterLevel(t *testing.T) {
+	dir := tempDir(t)
+	p := filepath.Join(dir, "app.log")
+	t.Cleanup(func() { _ = SetupLog(LogConfig{}) })
+
+	err := SetupLog(LogConfig{
+		Level:      "DEBUG",
+		FileWriter: ConfFileWriter{Enable: true, PathPattern: p, Level: "WARN"},
+		Loggers:    []ConfLogger{{Name: "com.team.payment", Level: "DEBUG"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	GetLogger("com.team.payment").Info("named info")
+	Warn("root warn")
+	Flush()
+
+	content := readTestFile(t, p)
+	if strings.Contains(content, "named info") || !strings.Contains(content, "root warn") {
+		t.Errorf("file = %q, the writer level is WARN", content)
+	}
+}
+
+func TestNamedLoggerKafkaMessage(t *testing.T) {
+	l, _ := newTestLogger(t)
+	f := newKafKaFormatter(&KafKaMSGFields{ESIndex: "idx"})
+	var buf bytes.Buffer
+
+	f.encode(&buf, &Record{level: INFO, info: "root", node: l.node})
+	if strings.Contains(buf.String(), `"logger"`) {
+		t.Errorf("root message = %s", buf.String())
+	}
+	buf.Reset()
+	f.encode(&buf, &Record{level: INFO, info: "named", node: l.GetLogger("com.team").node})
+	if !strings.Contains(buf.String(), `"logger":"com.team"`) {
+		t.Errorf("named message = %s", buf.String())
+	}
+}
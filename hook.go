@@ -0,0 +1,310 @@
+package log4go
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// Hook called with the records of its levels before they are written, it
+// may change the record, ex: add fields. A hook error or panic is logged, the
+// record is still written.
+//
+// A hook must not log to the logger it is added to: it is called while the
+// records are written, the log call would wait for the hook to return.
+type Hook interface {
+	Levels() []int
+	Fire(*Record) error
+}
+
+// Filter decide whether a record is written, called after the hooks. A
+// panicking filter is logged and allows the record. Like a hook, it must not
+// log to its logger.
+type Filter interface {
+	Allow(*Record) bool
+}
+
+// FilterFunc func as a Filter
+type FilterFunc func(*Record) bool
+
+// Allow call f
+func (f FilterFunc) Allow(r *Record) bool {
+	return f(r)
+}
+
+// AllLevels every level, for Hook.Levels
+func AllLevels() []int {
+	levels := make([]int, len(LevelFlags))
+	for i := range levels {
+		levels[i] = i
+	}
+	return levels
+}
+
+// AddField add the field to the record, for the hooks
+func (r *Record) AddField(key string, value interface{}) {
+	r.fields = append(r.fields, Field{Key: key, Value: value})
+}
+
+// SetMessage replace the message of the record, for the hooks
+func (r *Record) SetMessage(msg string) {
+	r.info = msg
+}
+
+// pipeline the hooks and filters of a logger or a HookWriter
+type pipeline struct {
+	hooks   [len(LevelFlags)][]Hook
+	filters []Filter
+}
+
+func (p *pipeline) addHook(h Hook) {
+	for _, level := range h.Levels() {
+		if level >= TRACE && level < len(p.hooks) {
+			p.hooks[level] = append(p.hooks[level], h)
+		}
+	}
+}
+
+// run fire the hooks then return whether the filters allow the record
+func (p *pipeline) run(r *Record) bool {
+	for _, h := range p.hooks[r.level] {
+		if err := fireHook(h, r); err != nil {
+			writeError(r, err)
+		}
+	}
+	for _, f := range p.filters {
+		if !allowRecord(f, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// fireHook fire h, a panic is returned as an error so the writer goroutine keeps running
+func fireHook(h Hook, r *Record) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("log4go: hook %T panic: %v", h, p)
+		}
+	}()
+	return h.Fire(r)
+}
+
+// allowRecord whether f allows r, true if f panics
+func allowRecord(f Filter, r *Record) (ok bool) {
+	defer func() {
+		if p := recover(); p != nil {
+			writeError(r, fmt.Errorf("log4go: filter %T panic: %v", f, p))
+			ok = true
+		}
+	}()
+	return f.Allow(r)
+}
+
+func (p *pipeline) hooksEmpty() bool {
+	for _, hs := range p.hooks {
+		if len(hs) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// AddHook fire h with the records of its levels before they are written,
+// on the writer goroutine, or in the log call in sync mode. h must not log to l.
+func (l *Logger) AddHook(h Hook) {
+	l.writeLock.Lock()
+	l.pipeline.addHook(h)
+	l.writeLock.Unlock()
+}
+
+// AddFilter write only the records f allows
+func (l *Logger) AddFilter(f Filter) {
+	l.writeLock.Lock()
+	l.pipeline.filters = append(l.pipeline.filters, f)
+	l.writeLock.Unlock()
+}
+
+// HookWriter wrap a writer with its own hooks and filters. The hooks change
+// a copy of the record, the other writers do not see the changes.
+type HookWriter struct {
+	w        Writer
+	pipeline pipeline
+	r        Record
+}
+
+// NewHookWriter wrap w, add the hooks and filters before registering it
+func NewHookWriter(w Writer) *HookWriter {
+	return &HookWriter{w: w}
+}
+
+// AddHook fire h with the records of its levels before w writes them
+func (w *HookWriter) AddHook(h Hook) *HookWriter {
+	w.pipeline.addHook(h)
+	return w
+}
+
+// AddFilter w writes only the records f allows
+func (w *HookWriter) AddFilter(f Filter) *HookWriter {
+	w.pipeline.filters = append(w.pipeline.filters, f)
+	return w
+}
+
+// Unwrap the wrapped writer
+func (w *HookWriter) Unwrap() Writer {
+	return w.w
+}
+
+// Init init the wrapped writer
+func (w *HookWriter) Init() error {
+	return w.w.Init()
+}
+
+// Write run the hooks on a copy of the record, write it if the filters allow it
+func (w *HookWriter) Write(r *Record) error {
+	if w.pipeline.hooksEmpty() {
+		if !w.pipeline.run(r) {
+			return nil
+		}
+		return w.w.Write(r)
+	}
+	w.r.copyFrom(r)
+	if !w.pipeline.run(&w.r) {
+		return nil
+	}
+	return w.w.Write(&w.r)
+}
+
+// Flush flush the wrapped writer
+func (w *HookWriter) Flush() error {
+	if f, ok := w.w.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Sync sync the wrapped writer, see AsyncWriter.Sync
+func (w *HookWriter) Sync() error {
+	if s, ok := w.w.(syncer); ok {
+		return s.Sync()
+	}
+	return w.Flush()
+}
+
+// Rotate rotate the wrapped writer
+func (w *HookWriter) Rotate() error {
+	if r, ok := w.w.(Rotater); ok {
+		return r.Rotate()
+	}
+	return nil
+}
+
+// SetPathPattern set the path pattern of the wrapped writer
+func (w *HookWriter) SetPathPattern(pattern string) error {
+	if r, ok := w.w.(Rotater); ok {
+		return r.SetPathPattern(pattern)
+	}
+	return errors.New("writer does not support path pattern")
+}
+
+// Close close the wrapped writer
+func (w *HookWriter) Close() error {
+	if c, ok := w.w.(Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Level the level of the wrapped writer, -1 if it has no level
+func (w *HookWriter) Level() int {
+	if lw, ok := w.w.(Leveler); ok {
+		return lw.Level()
+	}
+	return -1
+}
+
+// SetLevel set the level of the wrapped writer
+func (w *HookWriter) SetLevel(level int) {
+	if lw, ok := w.w.(Leveler); ok {
+		lw.SetLevel(level)
+	}
+}
+
+// HostHook hook adding the hostname and pid fields to every record
+type HostHook struct {
+	hostname string
+	pid      int
+}
+
+// NewHostHook create hook adding the hostname and pid fields
+func NewHostHook() *HostHook {
+	hostname, err := os.Hostname()
+	if err != nil {
+		logPrintln(err)
+	}
+	return &HostHook{hostname: hostname, pid: os.Getpid()}
+}
+
+// Levels every level
+func (h *HostHook) Levels() []int {
+	return AllLevels()
+}
+
+// Fire add the fields
+func (h *HostHook) Fire(r *Record) error {
+	r.fields = append(r.fields, Field{Key: "hostname", Value: h.hostname}, Field{Key: "pid", Value: h.pid})
+	return nil
+}
+
+// MessageFilter filter dropping the records whose message matches the regexp
+type MessageFilter struct {
+	re *regexp.Regexp
+}
+
+// NewMessageFilter create filter dropping the records whose message matches expr
+func NewMessageFilter(expr string) (*MessageFilter, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &MessageFilter{re: re}, nil
+}
+
+// Allow whether the message does not match
+func (f *MessageFilter) Allow(r *Record) bool {
+	return !f.re.MatchString(r.info)
+}
+
+// FieldFilter filter allowing the records whose field has one of the values,
+// added to a HookWriter it routes the records to the writer by field value
+type FieldFilter struct {
+	key    string
+	values map[string]bool
+}
+
+// NewFieldFilter create filter allowing the records whose key field, as text,
+// is one of values
+func NewFieldFilter(key string, values ...string) *FieldFilter {
+	f := &FieldFilter{key: key, values: make(map[string]bool, len(values))}
+	for _, v := range values {
+		f.values[v] = true
+	}
+	return f
+}
+
+// Allow whether the last field with the key has one of the values
+func (f *FieldFilter) Allow(r *Record) bool {
+	i := lastFieldIndex(r.fields, f.key)
+	return i >= 0 && f.values[formatFieldValue(r.fields[i].Value)]
+}
+
+// AddHook loggerDefault fire h before the records are written
+func AddHook(h Hook) {
+	loggerDefault.AddHook(h)
+}
+
+// AddFilter loggerDefault write only the records f allows
+func AddFilter(f Filter) {
+	loggerDefault.AddFilter(f)
+}
@@ -0,0 +1,139 @@
+package log4go
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// hookFunc hook of every level calling fire
+type hookFunc func(*Record) error
+
+func (hookFunc) Levels() []int {
+	return AllLevels()
+}
+
+func (f hookFunc) Fire(r *Record) error {
+	return f(r)
+}
+
+// syncBuffer bytes.Buffer safe for the log package output
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.String()
+}
+
+// captureStdLog collect the output of the log package until the test ends
+func captureStdLog(t *testing.T) *syncBuffer {
+	buf := &syncBuffer{}
+	prev := log.Writer()
+	log.SetOutput(buf)
+	t.Cleanup(func() { log.SetOutput(prev) })
+	return buf
+}
+
+func TestHookPanicRecovered(t *testing.T) {
+	for _, syncMode := range []bool{false, true} {
+		stdLog := captureStdLog(t)
+		l, w := newTestLogger(t)
+		l.SetSyncMode(syncMode, FATAL+1)
+		l.AddHook(hookFunc(func(r *Record) error {
+			if r.info == "boom" {
+				panic("bad hook")
+			}
+			return nil
+		}))
+		l.AddFilter(FilterFunc(func(r *Record) bool {
+			if r.info == "filter boom" {
+				panic("bad filter")
+			}
+			return true
+		}))
+
+		done := make(chan struct{})
+		go func() {
+			l.Info("before")
+			l.Info("boom")
+			l.Info("filter boom")
+			l.Info("after")
+			l.Flush()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("sync %v: logging stalled after a hook panic", syncMode)
+		}
+
+		if got := strings.Join(w.messages(), ","); got != "before,boom,filter boom,after" {
+			t.Errorf("sync %v: messages = %s", syncMode, got)
+		}
+		out := stdLog.String()
+		if !strings.Contains(out, "hook log4go.hookFunc panic: bad hook") || !strings.Contains(out, "filter log4go.FilterFunc panic: bad filter") {
+			t.Errorf("sync %v: log output = %q", syncMode, out)
+		}
+	}
+}
+
+func TestHookErrorStillWritten(t *testing.T) {
+	stdLog := captureStdLog(t)
+	l, w := newTestLogger(t)
+	l.AddHook(hookFunc(func(r *Record) error {
+		r.AddField("hooked", true)
+		return errors.New("hook failed")
+	}))
+	l.Info("msg")
+	l.Flush()
+
+	if len(w.records) != 1 || len(w.records[0].fields) != 1 || w.records[0].fields[0].Key != "hooked" {
+		t.Fatalf("records = %+v", w.records)
+	}
+	if !strings.Contains(stdLog.String(), "hook failed") {
+		t.Errorf("log output = %q", stdLog.String())
+	}
+}
+
+func TestHookWriterPipeline(t *testing.T) {
+	l, all := newTestLogger(t)
+	payments := &memWriter{}
+	hw := NewHookWriter(payments).
+		AddHook(hookFunc(func(r *Record) error {
+			r.SetMessage("[pay] " + r.info)
+			return nil
+		})).
+		AddFilter(NewFieldFilter("module", "payment"))
+	l.Register(hw)
+	noisy, err := NewMessageFilter(`^healthz`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.AddFilter(noisy)
+
+	l.Infow("charged", "module", "payment")
+	l.Infow("signed in", "module", "user")
+	l.Info("healthz ok")
+	l.Flush()
+
+	if got := strings.Join(all.messages(), ","); got != "charged,signed in" {
+		t.Errorf("all = %s", got)
+	}
+	if got := strings.Join(payments.messages(), ","); got != "[pay] charged" {
+		t.Errorf("payments = %s", got)
+	}
+}
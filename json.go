@@ -0,0 +1,262 @@
+package log4go
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// JSONFormatter formatter of one JSON object per line, ex:
+//
+//	{"time":"2006-01-02T15:04:05.999999999+08:00","level":"INFO","caller":"main.go:12","message":"hi","uid":7}
+//
+// The record fields never replace the time, level, caller, message and static
+// fields, the extra fields are only written if no other key has the same name.
+type JSONFormatter struct {
+	TimeKey     string // default time
+	LevelKey    string // default level
+	CallerKey   string // default caller
+	MessageKey  string // default message
+	StackKey    string // default stack, an array of "function file:line", only if the record has a stack
+	LoggerKey   string // default logger, the name of the logger, only for a named logger
+	TimeLayout  string // default time.RFC3339Nano
+	UnixTimeKey string // if set, the unix timestamp in second is written too
+
+	StaticFields []Field // written after the message
+	ExtraFields  []Field // written last
+}
+
+// NewJSONFormatter create json formatter with the default keys
+func NewJSONFormatter() *JSONFormatter {
+	return &JSONFormatter{}
+}
+
+// Format write the record as a JSON object followed by a newline
+func (f *JSONFormatter) Format(buf *bytes.Buffer, r *Record) {
+	f.encode(buf, r)
+	buf.WriteByte('\n')
+}
+
+// encode write the record as a JSON object
+func (f *JSONFormatter) encode(buf *bytes.Buffer, r *Record) {
+	var used [16]string
+	keys := used[:0]
+
+	buf.WriteByte('{')
+	keys = writeJSONKey(buf, keys, orDefault(f.TimeKey, "time"))
+	var tmp [64]byte
+	buf.WriteByte('"')
+	buf.Write(r.ts.AppendFormat(tmp[:0], orDefault(f.TimeLayout, time.RFC3339Nano)))
+	buf.WriteByte('"')
+	if f.UnixTimeKey != "" {
+		keys = writeJSONKey(buf, keys, f.UnixTimeKey)
+		buf.Write(strconv.AppendInt(tmp[:0], r.ts.Unix(), 10))
+	}
+	keys = writeJSONKey(buf, keys, orDefault(f.LevelKey, "level"))
+	writeJSONString(buf, LevelFlags[r.level])
+	keys = writeJSONKey(buf, keys, orDefault(f.CallerKey, "caller"))
+	writeJSONString(buf, r.code)
+	keys = writeJSONKey(buf, keys, orDefault(f.MessageKey, "message"))
+	writeJSONString(buf, r.info)
+	if r.node != nil {
+		keys = writeJSONKey(buf, keys, orDefault(f.LoggerKey, "logger"))
+		writeJSONString(buf, r.node.name)
+	}
+	if len(r.stack) > 0 {
+		keys = writeJSONKey(buf, keys, orDefault(f.StackKey, "stack"))
+		buf.WriteByte('[')
+		for i, sf := range r.stack {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSONString(buf, sf.String())
+		}
+		buf.WriteByte(']')
+	}
+
+	for _, sf := range f.StaticFields {
+		keys = writeJSONKey(buf, keys, sf.Key)
+		writeJSONValue(buf, sf.Value)
+	}
+	fixed := len(keys)
+	// the last field with the same key wins, like the text writers show it
+	for i, rf := range r.fields {
+		if containsKey(keys[:fixed], rf.Key) || lastFieldIndex(r.fields, rf.Key) != i {
+			continue
+		}
+		keys = writeJSONKey(buf, keys, rf.Key)
+		writeJSONValue(buf, rf.Value)
+	}
+	for _, ef := range f.ExtraFields {
+		if containsKey(keys, ef.Key) {
+			continue
+		}
+		keys = writeJSONKey(buf, keys, ef.Key)
+		writeJSONValue(buf, ef.Value)
+	}
+	buf.WriteByte('}')
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+func containsKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func lastFieldIndex(fields []Field, key string) int {
+	for i := len(fields) - 1; i >= 0; i-- {
+		if fields[i].Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// writeJSONKey write ,"key": and remember the key
+func writeJSONKey(buf *bytes.Buffer, keys []string, key string) []string {
+	if len(keys) > 0 {
+		buf.WriteByte(',')
+	}
+	writeJSONString(buf, key)
+	buf.WriteByte(':')
+	return append(keys, key)
+}
+
+// writeJSONValue write the value without reflection for the common types
+func writeJSONValue(buf *bytes.Buffer, v interface{}) {
+	var tmp [32]byte
+	switch val := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case string:
+		writeJSONString(buf, val)
+	case bool:
+		buf.Write(strconv.AppendBool(tmp[:0], val))
+	case int:
+		buf.Write(strconv.AppendInt(tmp[:0], int64(val), 10))
+	case int8:
+		buf.Write(strconv.AppendInt(tmp[:0], int64(val), 10))
+	case int16:
+		buf.Write(strconv.AppendInt(tmp[:0], int64(val), 10))
+	case int32:
+		buf.Write(strconv.AppendInt(tmp[:0], int64(val), 10))
+	case int64:
+		buf.Write(strconv.AppendInt(tmp[:0], val, 10))
+	case uint:
+		buf.Write(strconv.AppendUint(tmp[:0], uint64(val), 10))
+	case uint8:
+		buf.Write(strconv.AppendUint(tmp[:0], uint64(val), 10))
+	case uint16:
+		buf.Write(strconv.AppendUint(tmp[:0], uint64(val), 10))
+	case uint32:
+		buf.Write(strconv.AppendUint(tmp[:0], uint64(val), 10))
+	case uint64:
+		buf.Write(strconv.AppendUint(tmp[:0], val, 10))
+	case float32:
+		writeJSONFloat(buf, float64(val), 32)
+	case float64:
+		writeJSONFloat(buf, val, 64)
+	case time.Duration:
+		writeJSONString(buf, val.String())
+	case time.Time:
+		writeJSONString(buf, val.Format(time.RFC3339Nano))
+	case []byte:
+		writeJSONString(buf, string(val))
+	case error:
+		writeJSONString(buf, val.Error())
+	case json.Marshaler:
+		writeJSONMarshal(buf, val)
+	case fmt.Stringer:
+		writeJSONString(buf, val.String())
+	default:
+		writeJSONMarshal(buf, val)
+	}
+}
+
+func writeJSONMarshal(buf *bytes.Buffer, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		writeJSONString(buf, fmt.Sprint(v))
+		return
+	}
+	buf.Write(b)
+}
+
+func writeJSONFloat(buf *bytes.Buffer, f float64, bits int) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		writeJSONString(buf, strconv.FormatFloat(f, 'g', -1, bits))
+		return
+	}
+	var tmp [32]byte
+	buf.Write(strconv.AppendFloat(tmp[:0], f, 'g', -1, bits))
+}
+
+const hexDigits = "0123456789abcdef"
+
+// writeJSONString write the quoted string, like encoding/json without the html escaping
+func writeJSONString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' {
+				i++
+				continue
+			}
+			buf.WriteString(s[start:i])
+			switch c {
+			case '"', '\\':
+				buf.WriteByte('\\')
+				buf.WriteByte(c)
+			case '\n':
+				buf.WriteString(`\n`)
+			case '\r':
+				buf.WriteString(`\r`)
+			case '\t':
+				buf.WriteString(`\t`)
+			default:
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hexDigits[c>>4])
+				buf.WriteByte(hexDigits[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf.WriteString(s[start:i])
+			buf.WriteString(`\ufffd`)
+			i += size
+			start = i
+			continue
+		}
+		// U+2028 and U+2029 break the javascript parsers
+		if r == '\u2028' || r == '\u2029' {
+			buf.WriteString(s[start:i])
+			buf.WriteString(`\u202`)
+			buf.WriteByte(hexDigits[r&0xf])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	buf.WriteString(s[start:])
+	buf.WriteByte('"')
+}
@@ -0,0 +1,139 @@
+package log4go
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+type jsonMarshalerValue struct{}
+
+func (jsonMarshalerValue) MarshalJSON() ([]byte, error) {
+	return []byte(`{"m":1}`), nil
+}
+
+type stringerValue struct{}
+
+func (stringerValue) String() string {
+	return "stringer"
+}
+
+func TestWriteJSONValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"nil", nil, `null`},
+		{"string", "hi", `"hi"`},
+		{"bool", true, `true`},
+		{"int", -7, `-7`},
+		{"int8", int8(-8), `-8`},
+		{"int64", int64(math.MaxInt64), `9223372036854775807`},
+		{"uint64", uint64(math.MaxUint64), `18446744073709551615`},
+		{"uint8", uint8(255), `255`},
+		{"float32", float32(1.5), `1.5`},
+		{"float64", 0.1, `0.1`},
+		{"nan", math.NaN(), `"NaN"`},
+		{"inf", math.Inf(1), `"+Inf"`},
+		{"duration", 1500 * time.Millisecond, `"1.5s"`},
+		{"time", time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC), `"2021-03-04T05:06:07Z"`},
+		{"bytes", []byte("raw"), `"raw"`},
+		{"error", errors.New("no such file"), `"no such file"`},
+		{"marshaler", jsonMarshalerValue{}, `{"m":1}`},
+		{"stringer", stringerValue{}, `"stringer"`},
+		{"map", map[string]int{"a": 1}, `{"a":1}`},
+		{"slice", []int{1, 2}, `[1,2]`},
+		{"struct", struct{ A int }{1}, `{"A":1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			writeJSONValue(&buf, tt.value)
+			if got := buf.String(); got != tt.want {
+				t.Fatalf("value = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteJSONString(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain", `"plain"`},
+		{"", `""`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`back\slash`, `"back\\slash"`},
+		{"a\nb\rc\td", `"a\nb\rc\td"`},
+		{"\x00\x1f", `"\u0000\u001f"`},
+		{"<html>&", `"<html>&"`},
+		{"日志", `"日志"`},
+		{"bad\xffutf8", `"bad\ufffdutf8"`},
+		{"line\u2028sep\u2029", `"line\u2028sep\u2029"`},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		writeJSONString(&buf, tt.in)
+		if buf.String() != tt.want {
+			t.Errorf("writeJSONString(%q) = %s, want %s", tt.in, buf.String(), tt.want)
+		}
+		if !json.Valid(buf.Bytes()) {
+			t.Errorf("writeJSONString(%q) is not valid JSON", tt.in)
+		}
+	}
+}
+
+func TestJSONFormatter(t *testing.T) {
+	r := &Record{
+		ts:    time.Date(2021, 3, 4, 5, 6, 7, 8, time.UTC),
+		code:  "main.go:12",
+		info:  "hi",
+		level: INFO,
+	}
+	tests := []struct {
+		name   string
+		f      *JSONFormatter
+		fields []Field
+		stack  []StackFrame
+		node   *loggerNode
+		want   string
+	}{
+		{"default keys", NewJSONFormatter(), nil, nil, nil,
+			`{"time":"2021-03-04T05:06:07.000000008Z","level":"INFO","caller":"main.go:12","message":"hi"}`},
+		{"fields", NewJSONFormatter(), []Field{F("uid", 7), F("ok", true)}, nil, nil,
+			`{"time":"2021-03-04T05:06:07.000000008Z","level":"INFO","caller":"main.go:12","message":"hi","uid":7,"ok":true}`},
+		{"last field wins", NewJSONFormatter(), []Field{F("uid", 7), F("name", "a"), F("uid", 8)}, nil, nil,
+			`{"time":"2021-03-04T05:06:07.000000008Z","level":"INFO","caller":"main.go:12","message":"hi","name":"a","uid":8}`},
+		{"fields never replace the fixed keys", NewJSONFormatter(), []Field{F("message", "x"), F("level", "x")}, nil, nil,
+			`{"time":"2021-03-04T05:06:07.000000008Z","level":"INFO","caller":"main.go:12","message":"hi"}`},
+		{"logger and stack", NewJSONFormatter(), nil, []StackFrame{{Function: "main.main", File: "main.go", Line: 9}},
+			&loggerNode{name: "db"},
+			`{"time":"2021-03-04T05:06:07.000000008Z","level":"INFO","caller":"main.go:12","message":"hi","logger":"db","stack":["main.main main.go:9"]}`},
+		{"custom keys", &JSONFormatter{TimeKey: "ts", LevelKey: "lvl", CallerKey: "src", MessageKey: "msg",
+			TimeLayout: "2006-01-02", UnixTimeKey: "unix"}, nil, nil, nil,
+			`{"ts":"2021-03-04","unix":1614834367,"lvl":"INFO","src":"main.go:12","msg":"hi"}`},
+		{"static and extra", &JSONFormatter{TimeLayout: "2006",
+			StaticFields: []Field{F("app", "api")},
+			ExtraFields:  []Field{F("env", "prod"), F("uid", 0)}},
+			[]Field{F("app", "x"), F("uid", 7)}, nil, nil,
+			`{"time":"2021","level":"INFO","caller":"main.go:12","message":"hi","app":"api","uid":7,"env":"prod"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := *r
+			rr.fields, rr.stack, rr.node = tt.fields, tt.stack, tt.node
+			var buf bytes.Buffer
+			tt.f.Format(&buf, &rr)
+			if got := buf.String(); got != tt.want+"\n" {
+				t.Fatalf("Format =\n%s\nwant\n%s", got, tt.want)
+			}
+			if !json.Valid(buf.Bytes()) {
+				t.Fatal("not valid JSON")
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package log4go
+
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Formatter format a record into the buffer, used by the text writers
+type Formatter interface {
+	Format(buf *bytes.Buffer, r *Record)
+}
+
+// PatternLayout formatter like log4j's PatternLayout, the conversions are:
+//
+//	%d{layout}  time, formatted with the go time layout, %d is the logger time
+//	%p          level
+//	%c          logger name, empty for the root logger
+//	%F          source file
+//	%L          source line
+//	%l          source file:line
+//	%M          function
+//	%m          message
+//	%n          newline
+//	%X{field}   field value, %X is all the fields as k=v
+//	%%          percent sign
+//
+// A conversion may have a format modifier between % and the conversion character,
+// %-5p left justify the level to 5 characters, %5p right justify it,
+// %.20F truncate the file from the beginning to 20 characters.
+// Only %d and %X take a {argument}.
+// The stack of the record, if any, is written after the pattern.
+type PatternLayout struct {
+	pattern string
+	items   []patternItem
+}
+
+type patternItem struct {
+	conv     byte // 0 for literal text
+	text     string
+	leftJust bool
+	min      int
+	max      int
+}
+
+// NewPatternLayout compile the pattern, ex: "%d{2006-01-02 15:04:05.000} %-5p [%l] %m%n"
+func NewPatternLayout(pattern string) (*PatternLayout, error) {
+	pl := &PatternLayout{pattern: pattern}
+	literal := make([]byte, 0, len(pattern))
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		if c != '%' {
+			literal = append(literal, c)
+			continue
+		}
+		i++
+		if i >= len(pattern) {
+			return nil, errors.New("Invalid layout pattern (" + pattern + "), ends with %")
+		}
+		if pattern[i] == '%' {
+			literal = append(literal, '%')
+			continue
+		}
+		if len(literal) > 0 {
+			pl.items = append(pl.items, patternItem{text: string(literal)})
+			literal = literal[:0]
+		}
+
+		item := patternItem{}
+		if pattern[i] == '-' {
+			item.leftJust = true
+			i++
+		}
+		item.min, i = parsePatternInt(pattern, i)
+		if i < len(pattern) && pattern[i] == '.' {
+			item.max, i = parsePatternInt(pattern, i+1)
+		}
+		if i >= len(pattern) {
+			return nil, errors.New("Invalid layout pattern (" + pattern + "), missing conversion")
+		}
+		item.conv = pattern[i]
+		switch item.conv {
+		case 'd', 'p', 'c', 'F', 'L', 'l', 'M', 'm', 'n', 'X':
+		default:
+			return nil, errors.New("Invalid layout pattern (" + pattern + "), unknown conversion %" + string(item.conv))
+		}
+		if i+1 < len(pattern) && pattern[i+1] == '{' {
+			if item.conv != 'd' && item.conv != 'X' {
+				return nil, errors.New("Invalid layout pattern (" + pattern + "), %" + string(item.conv) + " takes no {argument}")
+			}
+			end := strings.IndexByte(pattern[i+1:], '}')
+			if end < 0 {
+				return nil, errors.New("Invalid layout pattern (" + pattern + "), missing }")
+			}
+			item.text = pattern[i+2 : i+1+end]
+			i += 1 + end
+		}
+		pl.items = append(pl.items, item)
+	}
+	if len(literal) > 0 {
+		pl.items = append(pl.items, patternItem{text: string(literal)})
+	}
+	return pl, nil
+}
+
+// formatRecord format the record with f, r.String() if f is nil
+func formatRecord(buf *bytes.Buffer, f Formatter, r *Record) {
+	buf.Reset()
+	if f == nil {
+		buf.WriteString(r.String())
+		return
+	}
+	f.Format(buf, r)
+}
+
+// text formats for the writer config
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// newFormatter create the formatter of the writer config, nil for the default text
+func newFormatter(format, pattern string) (Formatter, error) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case FormatJSON:
+		return NewJSONFormatter(), nil
+	case "", FormatText:
+		if pattern == "" {
+			return nil, nil
+		}
+		return NewPatternLayout(pattern)
+	}
+	return nil, errors.New("invalid format (" + format + ")")
+}
+
+// MustPatternLayout like NewPatternLayout, panic if the pattern is invalid
+func MustPatternLayout(pattern string) *PatternLayout {
+	pl, err := NewPatternLayout(pattern)
+	if err != nil {
+		panic(err)
+	}
+	return pl
+}
+
+// String the pattern
+func (pl *PatternLayout) String() string {
+	return pl.pattern
+}
+
+// Format format the record following the pattern
+func (pl *PatternLayout) Format(buf *bytes.Buffer, r *Record) {
+	for i := range pl.items {
+		item := &pl.items[i]
+		if item.conv == 0 {
+			buf.WriteString(item.text)
+			continue
+		}
+		if item.min == 0 && item.max == 0 {
+			item.write(buf, r)
+			continue
+		}
+		start := buf.Len()
+		item.write(buf, r)
+		item.pad(buf, start)
+	}
+	writeStack(buf, r.stack)
+}
+
+func (item *patternItem) write(buf *bytes.Buffer, r *Record) {
+	switch item.conv {
+	case 'd':
+		if item.text == "" {
+			buf.WriteString(r.time)
+		} else {
+			var tmp [64]byte
+			buf.Write(r.ts.AppendFormat(tmp[:0], item.text))
+		}
+	case 'p':
+		buf.WriteString(LevelFlags[r.level])
+	case 'c':
+		buf.WriteString(r.LoggerName())
+	case 'F':
+		file, _ := splitCode(r.code)
+		buf.WriteString(file)
+	case 'L':
+		_, line := splitCode(r.code)
+		buf.WriteString(line)
+	case 'l':
+		buf.WriteString(r.code)
+	case 'M':
+		buf.WriteString(funcName(r.pc))
+	case 'm':
+		buf.WriteString(r.info)
+	case 'n':
+		buf.WriteByte('\n')
+	case 'X':
+		if item.text == "" {
+			for j, f := range r.fields {
+				if j > 0 {
+					buf.WriteByte(' ')
+				}
+				writeField(buf, f)
+			}
+			return
+		}
+		for j := len(r.fields) - 1; j >= 0; j-- {
+			if r.fields[j].Key == item.text {
+				buf.WriteString(formatFieldValue(r.fields[j].Value))
+				return
+			}
+		}
+	}
+}
+
+// pad justify or truncate the text written since start
+func (item *patternItem) pad(buf *bytes.Buffer, start int) {
+	b := buf.Bytes()
+	text := b[start:]
+	n := utf8.RuneCount(text)
+
+	if item.max > 0 && n > item.max {
+		// truncate from the beginning, like log4j
+		skip := 0
+		for k := n - item.max; k > 0; k-- {
+			_, size := utf8.DecodeRune(text[skip:])
+			skip += size
+		}
+		copy(b[start:], text[skip:])
+		buf.Truncate(len(b) - skip)
+		n = item.max
+	}
+	if n >= item.min {
+		return
+	}
+	spaces := strings.Repeat(" ", item.min-n)
+	if item.leftJust {
+		buf.WriteString(spaces)
+		return
+	}
+	tail := append([]byte(nil), buf.Bytes()[start:]...)
+	buf.Truncate(start)
+	buf.WriteString(spaces)
+	buf.Write(tail)
+}
+
+func parsePatternInt(pattern string, i int) (int, int) {
+	j := i
+	for j < len(pattern) && pattern[j] >= '0' && pattern[j] <= '9' {
+		j++
+	}
+	if j == i {
+		return 0, i
+	}
+	n, _ := strconv.Atoi(pattern[i:j])
+	return n, j
+}
+
+// splitCode split file:line
+func splitCode(code string) (string, string) {
+	if i := strings.LastIndexByte(code, ':'); i >= 0 {
+		return code[:i], code[i+1:]
+	}
+	return code, ""
+}
+
+// funcName the function name without the package path, ex: log4go.(*Logger).Info
+func funcName(pc uintptr) string {
+	if pc == 0 {
+		return ""
+	}
+	f, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	name := f.Function
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
@@ -0,0 +1,138 @@
+package log4go
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testLayoutRecord() *Record {
+	pc, _, _, _ := runtime.Caller(0)
+	return &Record{
+		time:   "2006/01/02 15:04:05",
+		ts:     time.Date(2021, 3, 4, 5, 6, 7, 8000000, time.UTC),
+		code:   "service/main.go:42",
+		info:   "hello",
+		level:  WARNING,
+		fields: []Field{F("uid", 7), F("name", "bob smith"), F("uid", 8)},
+		pc:     pc,
+		node:   &loggerNode{name: "db.pool"},
+	}
+}
+
+func TestPatternLayout(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{"%m", "hello"},
+		{"%m%n", "hello\n"},
+		{"%d", "2006/01/02 15:04:05"},
+		{"%d{2006-01-02T15:04:05.000}", "2021-03-04T05:06:07.008"},
+		{"%p", "WARN"},
+		{"[%-5p]", "[WARN ]"},
+		{"[%5p]", "[ WARN]"},
+		{"%c", "db.pool"},
+		{"%F", "service/main.go"},
+		{"%L", "42"},
+		{"%l", "service/main.go:42"},
+		{"%.7F", "main.go"},
+		{"[%-10.7F]", "[main.go   ]"},
+		{"%M", "log4go.testLayoutRecord"},
+		{"%X{uid}", "8"},
+		{"%X{name}", "bob smith"},
+		{"%X{none}|", "|"},
+		{"%X", `uid=7 name="bob smith" uid=8`},
+		{"100%% %m", "100% hello"},
+		{"{%m}", "{hello}"},
+		{"%d{15:04} %-5p <%l> %m %X{uid}%n", "05:06 WARN  <service/main.go:42> hello 8\n"},
+		{"no conversion", "no conversion"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			pl, err := NewPatternLayout(tt.pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			pl.Format(&buf, testLayoutRecord())
+			if got := buf.String(); got != tt.want {
+				t.Fatalf("Format = %q, want %q", got, tt.want)
+			}
+			if pl.String() != tt.pattern {
+				t.Fatalf("String = %q", pl.String())
+			}
+		})
+	}
+}
+
+func TestPatternLayoutInvalid(t *testing.T) {
+	tests := []struct {
+		pattern string
+		err     string
+	}{
+		{"%m%", "ends with %"},
+		{"%-5", "missing conversion"},
+		{"%q", "unknown conversion %q"},
+		{"%d{2006", "missing }"},
+		{"%m{x}", "%m takes no {argument}"},
+		{"%p{x}", "%p takes no {argument}"},
+		{"%-5L{x}", "%L takes no {argument}"},
+		{"%n{}", "%n takes no {argument}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			_, err := NewPatternLayout(tt.pattern)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("err = %v, want %s", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestPatternLayoutStack(t *testing.T) {
+	r := testLayoutRecord()
+	r.stack = []StackFrame{{Function: "main.main", File: "/src/main.go", Line: 9}}
+	var buf bytes.Buffer
+	MustPatternLayout("%m").Format(&buf, r)
+	if want := "hello\n\tmain.main\n\t\t/src/main.go:9\n"; buf.String() != want {
+		t.Fatalf("Format = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestNewFormatter(t *testing.T) {
+	tests := []struct {
+		format, pattern string
+		want            string // type of the formatter, empty for nil
+		err             bool
+	}{
+		{"", "", "", false},
+		{"text", "", "", false},
+		{" TEXT ", "%m", "*log4go.PatternLayout", false},
+		{"", "%m", "*log4go.PatternLayout", false},
+		{"json", "", "*log4go.JSONFormatter", false},
+		{"Json", "%m", "*log4go.JSONFormatter", false},
+		{"", "%m{x}", "", true},
+		{"xml", "", "", true},
+	}
+	for _, tt := range tests {
+		f, err := newFormatter(tt.format, tt.pattern)
+		if (err != nil) != tt.err {
+			t.Errorf("newFormatter(%q, %q) err = %v", tt.format, tt.pattern, err)
+		}
+		if err != nil {
+			continue
+		}
+		got := ""
+		if f != nil {
+			got = fmt.Sprintf("%T", f)
+		}
+		if got != tt.want {
+			t.Errorf("newFormatter(%q, %q) = %s, want %s", tt.format, tt.pattern, got, tt.want)
+		}
+	}
+}
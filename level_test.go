@@ -0,0 +1,120 @@
+package log4go
+
+import (
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+// the values are part of the API since TRACE was added, see the README
+func TestLevelValues(t *testing.T) {
+	for level, flag := range map[int]string{TRACE: "TRACE", DEBUG: "DEBUG", INFO: "INFO", WARNING: "WARN", ERROR: "ERROR", PANIC: "PANIC", FATAL: "FATAL"} {
+		if LevelFlags[level] != flag || getLevel(flag) != level {
+			t.Errorf("level %d: flag %s, getLevel %d", level, LevelFlags[level], getLevel(flag))
+		}
+	}
+	if TRACE != 0 || FATAL != 6 {
+		t.Errorf("TRACE = %d, FATAL = %d", TRACE, FATAL)
+	}
+}
+
+func TestWriterDefaultLevels(t *testing.T) {
+	for name, level := range map[string]int{
+		"file":    NewFileWriterWithLevel(-1, &ConfFileWriter{}).Level(),
+		"console": NewConsoleWriterWithLevel(len(LevelFlags), &ConfConsoleWriter{}).Level(),
+	} {
+		if level != DEBUG {
+			t.Errorf("%s default level = %s, want DEBUG", name, LevelFlags[level])
+		}
+	}
+}
+
+// countingStringer count how many times it is formatted
+type countingStringer struct {
+	n int32
+}
+
+func (s *countingStringer) String() string {
+	atomic.AddInt32(&s.n, 1)
+	return "counted"
+}
+
+func TestSetLevelFilters(t *testing.T) {
+	tests := []struct {
+		level int
+		want  []string
+	}{
+		{TRACE, []string{"trace", "debug", "info", "warn", "error"}},
+		{DEBUG, []string{"debug", "info", "warn", "error"}},
+		{INFO, []string{"info", "warn", "error"}},
+		{WARNING, []string{"warn", "error"}},
+		{ERROR, []string{"error"}},
+		{FATAL, nil},
+	}
+	for _, tt := range tests {
+		t.Run(LevelFlags[tt.level], func(t *testing.T) {
+			l, w := newTestLogger(t)
+			l.SetLevel(tt.level)
+			if l.Level() != tt.level {
+				t.Fatalf("Level = %d, want %d", l.Level(), tt.level)
+			}
+			s := &countingStringer{}
+			l.Trace("trace%v", s)
+			l.Debug("debug%v", s)
+			l.Info("info%v", s)
+			l.Warn("warn%v", s)
+			l.Error("error%v", s)
+			l.Flush()
+
+			want := make([]string, 0, len(tt.want))
+			for _, msg := range tt.want {
+				want = append(want, msg+"counted")
+			}
+			if msgs := w.messages(); !reflect.DeepEqual(msgs, want) {
+				t.Fatalf("messages = %v, want %v", msgs, want)
+			}
+			// the filtered records are not formatted
+			if n := atomic.LoadInt32(&s.n); int(n) != len(tt.want) {
+				t.Fatalf("formatted %d times, want %d", n, len(tt.want))
+			}
+			for level := TRACE; level <= FATAL; level++ {
+				if l.Enabled(level) != (level >= tt.level) {
+					t.Errorf("Enabled(%s) = %v", LevelFlags[level], l.Enabled(level))
+				}
+			}
+		})
+	}
+}
+
+func TestSetLevelIgnoresInvalid(t *testing.T) {
+	l, _ := newTestLogger(t)
+	l.SetLevel(WARNING)
+	for _, level := range []int{TRACE - 1, len(LevelFlags)} {
+		l.SetLevel(level)
+		if l.Level() != WARNING {
+			t.Errorf("SetLevel(%d) changed the level to %d", level, l.Level())
+		}
+	}
+}
+
+func TestSetLevelWhileLogging(t *testing.T) {
+	l, w := newTestLogger(t)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			l.Info("info")
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		l.SetLevel(INFO + i%2*(ERROR-INFO))
+	}
+	<-done
+	l.SetLevel(ERROR)
+	l.Info("dropped")
+	l.Error("kept")
+	l.Flush()
+	if msgs := w.messages(); msgs[len(msgs)-1] != "kept" {
+		t.Fatalf("last message = %s", msgs[len(msgs)-1])
+	}
+}
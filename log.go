@@ -1,41 +1,131 @@
 package log4go
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"os"
 	"path"
 	"runtime"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 var (
-	LevelFlags = [...]string{"DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+	LevelFlags = [...]string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "PANIC", "FATAL"}
 	recordPool *sync.Pool
 )
 
 const (
-	DEBUG = iota
+	TRACE = iota
+	DEBUG
 	INFO
 	WARNING
 	ERROR
+	PANIC
 	FATAL
 )
 
+// ExitFunc called by Fatal after the logger is closed, tests can replace it
+var ExitFunc = os.Exit
+
+// FatalCloseTimeout max time Fatal waits for the queued records to be written
+var FatalCloseTimeout = 10 * time.Second
+
 const tunnelSizeDefault = 1024
 
 // Record record struct
 type Record struct {
-	time  string
-	code  string
-	info  string
-	level int
+	time   string
+	code   string
+	info   string
+	level  int
+	fields []Field
+	ts     time.Time // time of the log call, time is formatted from it
+	pc     uintptr   // program counter of the log call, 0 if unknown
+	stack  []StackFrame
+	err    error       // set by Err
+	std    bool        // from a LineWriter or SaramaLogger, the write errors are not logged with the log package
+	node   *loggerNode // named logger of the record, nil for the root
+
+	barrier chan struct{} // not a log record, closed once the records before are written
 }
 
 // String record string
 func (r *Record) String() string {
-	return fmt.Sprintf("%s [%s] <%s> %s\n", r.time, LevelFlags[r.level], r.code, r.info)
+	if len(r.fields) == 0 && len(r.stack) == 0 {
+		return fmt.Sprintf("%s [%s] <%s> %s\n", r.time, LevelFlags[r.level], r.code, r.namedInfo())
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s [%s] <%s> %s", r.time, LevelFlags[r.level], r.code, r.namedInfo())
+	writeFields(&buf, r.fields)
+	buf.WriteByte('\n')
+	writeStack(&buf, r.stack)
+	return buf.String()
+}
+
+// Time time of the log call
+func (r *Record) Time() time.Time {
+	return r.ts
+}
+
+// Level level of the record
+func (r *Record) Level() int {
+	return r.level
+}
+
+// Code source code file:line of the log call
+func (r *Record) Code() string {
+	return r.code
+}
+
+// Message message of the record
+func (r *Record) Message() string {
+	return r.info
+}
+
+// LoggerName name of the logger of the record, empty for the root logger
+func (r *Record) LoggerName() string {
+	if r.node == nil {
+		return ""
+	}
+	return r.node.name
+}
+
+// namedInfo the message prefixed by [logger name], for the text output
+func (r *Record) namedInfo() string {
+	if r.node == nil {
+		return r.info
+	}
+	return "[" + r.node.name + "] " + r.info
+}
+
+// Fields fields of the record, owned by the record, copy them to keep them
+// after Write returns
+func (r *Record) Fields() []Field {
+	return r.fields
+}
+
+// Stack stack of the record, nil if not captured, owned by the record
+func (r *Record) Stack() []StackFrame {
+	return r.stack
+}
+
+// Err error of the record, set by Err
+func (r *Record) Err() error {
+	return r.err
+}
+
+// copyFrom copy the record, the fields are copied into the own slice
+func (r *Record) copyFrom(src *Record) {
+	fields := append(r.fields[:0], src.fields...)
+	stack := append(r.stack[:0], src.stack...)
+	*r = *src
+	r.fields = fields
+	r.stack = stack
 }
 
 // Writer writer interface
@@ -55,55 +145,305 @@ type Flusher interface {
 	Flush() error
 }
 
-// Logger log struct
+// Closer close interface, called when the writer is removed or the logger is closed
+type Closer interface {
+	Close() error
+}
+
+// syncer writer that can write its pending records and flush before returning
+type syncer interface {
+	Sync() error
+}
+
+// Leveler writer with a level adjustable at runtime
+type Leveler interface {
+	Level() int
+	SetLevel(int)
+}
+
+// Logger log struct, loggers created by With and GetLogger share the same core
 type Logger struct {
-	writers []Writer
-	tunnel  chan *Record
-	// level       int
+	*loggerCore
+	fields []Field     // attached to every record
+	node   *loggerNode // named logger, nil for the root
+}
+
+type loggerCore struct {
+	writers     []Writer
+	writersLock sync.RWMutex // held for reading while the writers are called
+	tunnel      chan *Record
+	level       int32 // minimum level, accessed atomically
 	lastTime    int64
 	lastTimeStr string
-	c           chan bool
+	done        chan struct{} // closed when the writer goroutine has stopped
 	layout      string
 
-	fullPath bool // show full path, default only show file:line_number
-	lock     sync.RWMutex
+	fullPath   int32 // show full path if 1, default only show file:line_number
+	extractors []ContextExtractor
+	lock       sync.RWMutex
+
+	sampler   *Sampler
+	reporting int32     // 1 while reportSampled runs, accessed atomically
+	redactor  *Redactor // guarded by lock, not writeLock, so it is read while a writer stalls
+
+	stackLevel int32 // records at this level or above get the stack, accessed atomically
+	stackDepth int
+	stackSkip  []string
+
+	tunnelSize    int // guarded by lock, the tunnel is created with the first record
+	startOnce     sync.Once
+	overflow      int32 // OverflowPolicy, accessed atomically
+	overflowLevel int32
+	drops         overflowStats
+
+	writeLock  sync.Mutex // held while the writers are called, by the writer goroutine or a sync log call
+	dedup      dedupState // guarded by writeLock
+	pipeline   pipeline   // hooks and filters, guarded by writeLock
+	sync       int32      // 1 if the log calls write the records themselves, accessed atomically
+	flushLevel int32      // in sync mode, the writers are flushed after a record at this level or above
+	syncOnce   sync.Once
+	modeLock   sync.RWMutex  // held for reading by the log calls, SetSyncMode holds it while the tunnel drains
+	syncStart  chan struct{} // closed by the first sync write, starts the flush and rotate timers
+
+	names     map[string]*loggerNode // named loggers, guarded by namesLock
+	nodes     []*loggerNode          // named loggers in creation order, guarded by namesLock
+	namesLock sync.Mutex
+
+	closeLock sync.RWMutex // held for reading while a record is sent to the tunnel
+	closed    bool
+	closing   chan struct{} // closed first by CloseContext, the blocked sends give up
+	closeOnce sync.Once
+	abort     int32 // set if CloseContext timed out, the queued records are discarded
+}
+
+// LoggerOption option for NewLogger
+type LoggerOption func(*Logger)
+
+// WithTunnelSize set the number of records buffered between the log calls and the writers
+func WithTunnelSize(size int) LoggerOption {
+	return func(l *Logger) {
+		if size > 0 {
+			l.tunnelSize = size
+		}
+	}
+}
+
+// WithOverflowPolicy set what to do when the tunnel is full, see SetOverflowPolicy
+func WithOverflowPolicy(p OverflowPolicy, level int) LoggerOption {
+	return func(l *Logger) {
+		l.overflow = int32(p)
+		l.overflowLevel = int32(level)
+	}
 }
 
-// NewLogger create the logger instance
-func NewLogger() *Logger {
-	if loggerDefault != nil && !takeUP {
+// NewLogger create the logger instance, the first call without option
+// returns the default logger
+func NewLogger(opts ...LoggerOption) *Logger {
+	if loggerDefault != nil && !takeUP && len(opts) == 0 {
 		takeUP = true
 		return loggerDefault
 	}
 
-	l := new(Logger)
+	l := &Logger{loggerCore: new(loggerCore)}
 	l.writers = make([]Writer, 0, 2)
-	l.tunnel = make(chan *Record, tunnelSizeDefault)
-	l.c = make(chan bool, 1)
-	// l.level = DEBUG
+	l.tunnelSize = tunnelSizeDefault
+	l.done = make(chan struct{})
+	l.closing = make(chan struct{})
+	l.syncStart = make(chan struct{})
+	l.flushLevel = int32(stackOff)
+	l.level = DEBUG
 	l.layout = "2006/01/02 15:04:05"
-
-	go bootstrapLogWriter(l)
+	l.extractors = []ContextExtractor{DefaultContextExtractor}
+	l.setStackTrace(stackOff, 0, nil)
+	for _, opt := range opts {
+		opt(l)
+	}
 
 	return l
 }
 
+// start create the tunnel and start the writer goroutine, called before the
+// first use of the tunnel
+func (l *Logger) start() {
+	l.startOnce.Do(func() {
+		l.lock.Lock()
+		l.tunnel = make(chan *Record, l.tunnelSize)
+		l.lock.Unlock()
+		go bootstrapLogWriter(l)
+	})
+}
+
+// SetTunnelSize Logger set the number of records buffered between the log calls
+// and the writers. The tunnel is created with the first record, its size can
+// not change after.
+func (l *Logger) SetTunnelSize(size int) error {
+	if size <= 0 {
+		return errors.New("invalid tunnel size (" + strconv.Itoa(size) + ")")
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.tunnel != nil {
+		if cap(l.tunnel) == size {
+			return nil
+		}
+		return errors.New("tunnel is in use, its size can not change")
+	}
+	l.tunnelSize = size
+	return nil
+}
+
 // Register register logger writer
 func (l *Logger) Register(w Writer) {
 	if err := w.Init(); err != nil {
 		panic(err)
 	}
-	l.writers = append(l.writers, w)
+	l.writersLock.Lock()
+	writers := l.ownWriters()
+	*writers = append(*writers, w)
+	l.writersLock.Unlock()
+}
+
+// Writers Logger get the writers registered on the logger, not those of its ancestors
+func (l *Logger) Writers() []Writer {
+	l.writersLock.RLock()
+	defer l.writersLock.RUnlock()
+	return append([]Writer(nil), *l.ownWriters()...)
+}
+
+// Unregister Logger remove the writer, it is flushed and closed. It is safe
+// to call while logging, the writer is not used after it returns.
+func (l *Logger) Unregister(w Writer) bool {
+	if !l.swapWriter(w, nil) {
+		return false
+	}
+	closeWriter(w)
+	return true
+}
+
+// Replace Logger init w and put it in place of old, old is flushed and closed.
+// It is safe to call while logging, no record is lost between the writers.
+func (l *Logger) Replace(old, w Writer) error {
+	if !l.hasWriter(old) {
+		return errors.New("writer to replace is not registered")
+	}
+	if err := w.Init(); err != nil {
+		return err
+	}
+	if !l.swapWriter(old, w) {
+		closeWriter(w)
+		return errors.New("writer to replace is not registered")
+	}
+	closeWriter(old)
+	return nil
+}
+
+func (l *Logger) hasWriter(w Writer) bool {
+	l.writersLock.RLock()
+	defer l.writersLock.RUnlock()
+	for _, cur := range *l.ownWriters() {
+		if cur == w {
+			return true
+		}
+	}
+	return false
+}
+
+// swapWriter replace old by w, old is removed if w is nil, w is appended if
+// old is nil. old is flushed before being removed, while no record is written.
+func (l *Logger) swapWriter(old, w Writer) bool {
+	l.writersLock.Lock()
+	defer l.writersLock.Unlock()
+
+	cur := l.ownWriters()
+	if old == nil {
+		*cur = append(*cur, w)
+		return true
+	}
+	for i, w0 := range *cur {
+		if w0 != old {
+			continue
+		}
+		if f, ok := old.(Flusher); ok {
+			if err := f.Flush(); err != nil {
+				logPrintln(err)
+			}
+		}
+		writers := make([]Writer, 0, len(*cur))
+		writers = append(writers, (*cur)[:i]...)
+		if w != nil {
+			writers = append(writers, w)
+		}
+		*cur = append(writers, (*cur)[i+1:]...)
+		return true
+	}
+	return false
 }
 
-// SetLevel Logger set level
+// closeWriter flush and close the writer removed from the logger
+func closeWriter(w Writer) {
+	if f, ok := w.(Flusher); ok {
+		if err := f.Flush(); err != nil {
+			logPrintln(err)
+		}
+	}
+	if c, ok := w.(Closer); ok {
+		if err := c.Close(); err != nil {
+			logPrintln(err)
+		}
+	}
+}
+
+// SetLevel Logger set the minimum level, records below it are dropped
+// before being formatted. It is safe to call while logging.
 func (l *Logger) SetLevel(lvl int) {
-	// l.level = lvl
+	if lvl < TRACE || lvl >= len(LevelFlags) {
+		return
+	}
+	if l.node != nil {
+		atomic.StoreInt32(&l.node.level, int32(lvl))
+		return
+	}
+	atomic.StoreInt32(&l.level, int32(lvl))
+}
+
+// Level Logger get the minimum level, a named logger inherits it if not set
+func (l *Logger) Level() int {
+	return int(l.minLevel())
+}
+
+// Enabled Logger report whether a record of the level would be delivered
+func (l *Logger) Enabled(level int) bool {
+	return int32(level) >= l.minLevel()
+}
+
+// With Logger create a child logger which attaches the key/value pairs
+// to every record, keys are strings or kv is made of Field
+func (l *Logger) With(kv ...interface{}) *Logger {
+	fields := make([]Field, 0, len(l.fields)+len(kv)/2)
+	fields = append(fields, l.fields...)
+	return &Logger{loggerCore: l.loggerCore, fields: fieldsFromKV(fields, kv), node: l.node}
+}
+
+// ShowFullPath Logger show the full path of the source file, default only file:line_number
+func (l *Logger) ShowFullPath(show bool) {
+	var v int32
+	if show {
+		v = 1
+	}
+	atomic.StoreInt32(&l.fullPath, v)
 }
 
 // SetLayout Logger set the time data format, layout
 func (l *Logger) SetLayout(layout string) {
+	l.lock.Lock()
 	l.layout = layout
+	l.lastTime = 0
+	l.lock.Unlock()
+}
+
+// Trace Logger deliver record to writer
+func (l *Logger) Trace(fmt string, args ...interface{}) {
+	l.deliverRecordToWriter(TRACE, fmt, args...)
 }
 
 // Debug Logger deliver record to writer
@@ -126,50 +466,270 @@ func (l *Logger) Error(fmt string, args ...interface{}) {
 	l.deliverRecordToWriter(ERROR, fmt, args...)
 }
 
-// Fatal Logger deliver record to writer
+// Panic Logger deliver record to writer, flush the writers then panic with the message
+func (l *Logger) Panic(fmt string, args ...interface{}) {
+	msg := formatMessage(fmt, args)
+	l.deliverRecordToWriter(PANIC, "%s", msg)
+	l.Flush()
+	panic(msg)
+}
+
+// Fatal Logger deliver record to writer, close the logger then exit the process
 func (l *Logger) Fatal(fmt string, args ...interface{}) {
+	deadline, cancel := l.fatalDeadline()
 	l.deliverRecordToWriter(FATAL, fmt, args...)
+	l.exit(deadline, cancel)
+}
+
+// fatalDeadline start the FatalCloseTimeout of a Fatal call. Once it expires
+// the logger is closing, so the fatal record can not block on a full tunnel either.
+func (l *Logger) fatalDeadline() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), FatalCloseTimeout)
+	go func() {
+		<-ctx.Done()
+		if ctx.Err() == context.DeadlineExceeded {
+			_, _ = l.CloseContext(ctx)
+		}
+	}()
+	return ctx, cancel
+}
+
+// exit close the logger, writing the queued records until the deadline, then call ExitFunc(1)
+func (l *Logger) exit(deadline context.Context, cancel context.CancelFunc) {
+	_, _ = l.CloseContext(deadline)
+	cancel()
+	ExitFunc(1)
+}
+
+// Flush Logger wait until the queued records are written, then flush the writers
+func (l *Logger) Flush() {
+	l.start()
+	barrier := make(chan struct{})
+	l.closeLock.RLock()
+	if l.closed {
+		l.closeLock.RUnlock()
+		<-l.done
+		return
+	}
+	// never dropped by the overflow policy
+	select {
+	case l.tunnel <- &Record{barrier: barrier}:
+		l.closeLock.RUnlock()
+		// the barrier may be given up by DropOldest while closing
+		select {
+		case <-barrier:
+		case <-l.done:
+		}
+	case <-l.closing:
+		l.closeLock.RUnlock()
+		<-l.done
+	}
+}
+
+// Tracew Logger deliver record with key/value pairs to writer
+func (l *Logger) Tracew(msg string, kv ...interface{}) {
+	l.deliverFieldsToWriter(TRACE, msg, kv)
+}
+
+// Debugw Logger deliver record with key/value pairs to writer
+func (l *Logger) Debugw(msg string, kv ...interface{}) {
+	l.deliverFieldsToWriter(DEBUG, msg, kv)
+}
+
+// Infow Logger deliver record with key/value pairs to writer
+func (l *Logger) Infow(msg string, kv ...interface{}) {
+	l.deliverFieldsToWriter(INFO, msg, kv)
+}
+
+// Warnw Logger deliver record with key/value pairs to writer
+func (l *Logger) Warnw(msg string, kv ...interface{}) {
+	l.deliverFieldsToWriter(WARNING, msg, kv)
+}
+
+// Errorw Logger deliver record with key/value pairs to writer
+func (l *Logger) Errorw(msg string, kv ...interface{}) {
+	l.deliverFieldsToWriter(ERROR, msg, kv)
+}
+
+// Panicw Logger deliver record with key/value pairs to writer, flush then panic
+func (l *Logger) Panicw(msg string, kv ...interface{}) {
+	l.deliverFieldsToWriter(PANIC, msg, kv)
+	l.Flush()
+	panic(msg)
 }
 
-// Close Logger close buffer, flush and stop logger
+// Fatalw Logger deliver record with key/value pairs to writer, close the logger then exit
+func (l *Logger) Fatalw(msg string, kv ...interface{}) {
+	deadline, cancel := l.fatalDeadline()
+	l.deliverFieldsToWriter(FATAL, msg, kv)
+	l.exit(deadline, cancel)
+}
+
+// Close Logger close buffer, flush and stop logger. It is safe to call more
+// than once, records logged after Close are written to stderr.
 func (l *Logger) Close() {
-	close(l.tunnel)
-	<-l.c
+	_, _ = l.CloseContext(context.Background())
+}
 
-	for _, w := range l.writers {
-		if f, ok := w.(Flusher); ok {
-			if err := f.Flush(); err != nil {
-				log.Println(err)
+// CloseContext Logger stop accepting records and wait until the queued records
+// are written, the writers flushed and closed. If ctx is done before, the
+// queued records are discarded and the number of them is returned with ctx.Err().
+func (l *Logger) CloseContext(ctx context.Context) (lost int, err error) {
+	l.start()
+	l.closeOnce.Do(func() {
+		// the sends blocked on a full tunnel give up and release the closeLock,
+		// a sync write may still hold it on a stalled writer, so it is taken
+		// on its own goroutine and ctx is not missed
+		close(l.closing)
+		go func() {
+			l.closeLock.Lock()
+			l.closed = true
+			close(l.tunnel)
+			l.closeLock.Unlock()
+		}()
+	})
+
+	select {
+	case <-l.done:
+		return 0, nil
+	case <-ctx.Done():
+		atomic.StoreInt32(&l.abort, 1)
+		return len(l.tunnel), ctx.Err()
+	}
+}
+
+// closeWriters flush and close the writers, called by the writer goroutine when it stops
+func (l *Logger) closeWriters() {
+	l.flushDedup(true)
+	l.flushWriters()
+
+	l.eachWriter(func(w Writer) {
+		if c, ok := w.(Closer); ok {
+			if err := c.Close(); err != nil {
+				logPrintln(err)
 			}
 		}
+	})
+}
+
+// sendOrDivert send the record to the tunnel, or write it to stderr if the logger is closed
+func (l *Logger) sendOrDivert(r *Record) {
+	l.start()
+	l.modeLock.RLock()
+	defer l.modeLock.RUnlock()
+	l.closeLock.RLock()
+	if !l.closed {
+		sent := true
+		if atomic.LoadInt32(&l.sync) == 1 {
+			l.writeSync(r)
+		} else {
+			sent = l.send(r)
+		}
+		if sent {
+			l.closeLock.RUnlock()
+			return
+		}
 	}
+	l.closeLock.RUnlock()
+
+	_, _ = os.Stderr.WriteString(r.String())
+	recordPool.Put(r)
 }
 
 func (l *Logger) deliverRecordToWriter(level int, format string, args ...interface{}) {
-	var inf, code string
+	var inf string
 
-	/*	if level < l.level {
+	if !l.Enabled(level) {
 		return
-	}*/
+	}
 
+	inf = formatMessage(format, args)
+
+	l.output(nil, 3, level, inf, nil, nil)
+}
+
+func formatMessage(format string, args []interface{}) string {
 	if format != "" {
-		inf = fmt.Sprintf(format, args...)
-	} else {
-		inf = fmt.Sprint(args...)
+		return fmt.Sprintf(format, args...)
+	}
+	return fmt.Sprint(args...)
+}
+
+func (l *Logger) deliverCtxToWriter(ctx context.Context, level int, format string, args ...interface{}) {
+	var inf string
+
+	if !l.Enabled(level) {
+		return
 	}
 
+	inf = formatMessage(format, args)
+
+	l.output(ctx, 3, level, inf, nil, nil)
+}
+
+func (l *Logger) deliverFieldsToWriter(level int, msg string, kv []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	l.output(nil, 3, level, msg, kv, nil)
+}
+
+// output build the record and send it to the tunnel, calldepth is the
+// number of frames to skip to reach the caller of the log method, err is set by Err
+func (l *Logger) output(ctx context.Context, calldepth int, level int, inf string, kv []interface{}, err error) {
 	// source code, file and line num
-	_, file, line, ok := runtime.Caller(2)
-	if ok {
-		if l.fullPath {
-			code = file + ":" + strconv.Itoa(line)
-		} else {
-			code = path.Base(file) + ":" + strconv.Itoa(line)
+	pc, code := l.caller(calldepth)
+	if !l.sample(code, level) {
+		return
+	}
+
+	r := l.newRecord(level, code, inf)
+	r.pc = pc
+	r.fields = append(r.fields, l.fields...)
+	r.fields = fieldsFromKV(r.fields, kv)
+	r.fields = l.extractContext(ctx, r.fields)
+	if err != nil {
+		r.err = err
+		r.fields = errorFields(r.fields, err)
+		if pcs := errorStack(err); len(pcs) > 0 {
+			r.stack = l.stackFrames(r.stack, pcs)
 		}
 	}
+	if len(r.stack) == 0 && int32(level) >= atomic.LoadInt32(&l.stackLevel) {
+		r.stack = l.captureStack(r.stack, calldepth)
+	}
+
+	l.sendOrDivert(r)
+}
+
+// caller pc and file:line of the caller, skip like runtime.Caller from the
+// function calling it. The pc is a runtime.Callers one, so inlined calls are
+// resolved by runtime.CallersFrames.
+func (l *Logger) caller(skip int) (uintptr, string) {
+	var pcs [1]uintptr
+	if runtime.Callers(skip+2, pcs[:]) == 0 {
+		return 0, ""
+	}
+	f, _ := runtime.CallersFrames(pcs[:]).Next()
+	return pcs[0], l.code(f.File, f.Line)
+}
+
+// code file:line of the source code, the file is the base name unless full path is set
+func (l *Logger) code(file string, line int) string {
+	if atomic.LoadInt32(&l.fullPath) == 1 {
+		return file + ":" + strconv.Itoa(line)
+	}
+	return path.Base(file) + ":" + strconv.Itoa(line)
+}
 
+// newRecord get a record from the pool, with the formatted time
+func (l *Logger) newRecord(level int, code string, inf string) *Record {
+	return l.newRecordAt(time.Now(), level, code, inf)
+}
+
+// newRecordAt like newRecord, for a log call made at now
+func (l *Logger) newRecordAt(now time.Time, level int, code string, inf string) *Record {
 	// format time
-	now := time.Now()
 	l.lock.Lock() // avoid data race
 	if now.Unix() != l.lastTime {
 		l.lastTime = now.Unix()
@@ -181,11 +741,120 @@ func (l *Logger) deliverRecordToWriter(level int, format string, args ...interfa
 	r := recordPool.Get().(*Record)
 	r.info = inf
 	r.code = code
-	// r.time = l.lastTimeStr
 	r.time = lastTimeStr
 	r.level = level
+	r.fields = r.fields[:0]
+	r.stack = r.stack[:0]
+	r.err = nil
+	r.std = false
+	r.ts = now
+	r.pc = 0
+	r.node = l.node
+	return r
+}
+
+// writeToWriters write the record to every writer
+func (l *Logger) writeToWriters(r *Record) {
+	l.writeLock.Lock()
+	defer l.writeLock.Unlock()
+	if !l.pipeline.run(r) {
+		return
+	}
+	if rd := l.Redactor(); rd != nil {
+		rd.redactRecord(r)
+	}
+	if l.dedup.window > 0 && !l.dedupRecord(r) {
+		return
+	}
+	l.writeRecord(r)
+}
+
+// writeRecord write the record to the writers of its logger and of the
+// ancestors while they are additive, the writeLock is held
+func (l *Logger) writeRecord(r *Record) {
+	l.writersLock.RLock()
+	defer l.writersLock.RUnlock()
+	for n := r.node; n != nil; n = n.parent {
+		writeRecordTo(n.writers, r)
+		if atomic.LoadInt32(&n.additive) == 0 {
+			return
+		}
+	}
+	writeRecordTo(l.writers, r)
+}
 
-	l.tunnel <- r
+func writeRecordTo(writers []Writer, r *Record) {
+	for _, w := range writers {
+		if err := w.Write(r); err != nil {
+			writeError(r, err)
+		}
+	}
+}
+
+// writeError log the error of writing r, to stderr if r came from the log
+// package, so a redirected log package can not loop
+func writeError(r *Record, err error) {
+	if r.std {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	logPrintln(err)
+}
+
+// flushWriters flush every Flusher writer
+func (l *Logger) flushWriters() {
+	l.writeLock.Lock()
+	defer l.writeLock.Unlock()
+	l.eachWriter(func(w Writer) {
+		if f, ok := w.(Flusher); ok {
+			if err := f.Flush(); err != nil {
+				logPrintln(err)
+			}
+		}
+	})
+}
+
+// syncWriters flush every Flusher writer, the async writers are drained first
+func (l *Logger) syncWriters() {
+	l.writeLock.Lock()
+	defer l.writeLock.Unlock()
+	l.eachWriter(func(w Writer) {
+		var err error
+		if s, ok := w.(syncer); ok {
+			err = s.Sync()
+		} else if f, ok := w.(Flusher); ok {
+			err = f.Flush()
+		}
+		if err != nil {
+			logPrintln(err)
+		}
+	})
+}
+
+// rotateWriters rotate every Rotater writer
+func (l *Logger) rotateWriters() {
+	l.writeLock.Lock()
+	defer l.writeLock.Unlock()
+	l.eachWriter(func(w Writer) {
+		if r, ok := w.(Rotater); ok {
+			if err := r.Rotate(); err != nil {
+				logPrintln(err)
+			}
+		}
+	})
+}
+
+// handleRecord write the record taken from the tunnel and put it back to the pool
+func (l *Logger) handleRecord(r *Record) {
+	if r.barrier != nil {
+		l.syncWriters()
+		close(r.barrier)
+		return
+	}
+	if atomic.LoadInt32(&l.abort) == 0 {
+		l.writeToWriters(r)
+	}
+	recordPool.Put(r)
 }
 
 func bootstrapLogWriter(logger *Logger) {
@@ -198,15 +867,17 @@ func bootstrapLogWriter(logger *Logger) {
 		ok bool
 	)
 
-	if r, ok = <-logger.tunnel; !ok {
-		logger.c <- true
-		return
-	}
+	defer close(logger.done)
+	defer logger.closeWriters()
 
-	for _, w := range logger.writers {
-		if err := w.Write(r); err != nil {
-			log.Println(err)
+	// the timers start with the first record
+	select {
+	case r, ok = <-logger.tunnel:
+		if !ok {
+			return
 		}
+		logger.handleRecord(r)
+	case <-logger.syncStart:
 	}
 
 	flushTimer := time.NewTimer(time.Millisecond * 500)
@@ -216,36 +887,20 @@ func bootstrapLogWriter(logger *Logger) {
 		select {
 		case r, ok = <-logger.tunnel:
 			if !ok {
-				logger.c <- true
 				return
 			}
-
-			for _, w := range logger.writers {
-				if err := w.Write(r); err != nil {
-					log.Println(err)
-				}
-			}
-
-			recordPool.Put(r)
+			logger.handleRecord(r)
+			logger.reportDropped()
 
 		case <-flushTimer.C:
-			for _, w := range logger.writers {
-				if f, ok := w.(Flusher); ok {
-					if err := f.Flush(); err != nil {
-						log.Println(err)
-					}
-				}
-			}
+			logger.flushDedup(false)
+			logger.flushWriters()
+			logger.reportDropped()
+			go logger.reportSampled()
 			flushTimer.Reset(time.Millisecond * 1000)
 
 		case <-rotateTimer.C:
-			for _, w := range logger.writers {
-				if r, ok := w.(Rotater); ok {
-					if err := r.Rotate(); err != nil {
-						log.Println(err)
-					}
-				}
-			}
+			logger.rotateWriters()
 			rotateTimer.Reset(time.Second * 10)
 		}
 	}
@@ -257,15 +912,24 @@ var (
 	takeUP        = false
 )
 
-// SetLevel global set level is ignore
-// logger level should be set in specific logger
+// SetLevel loggerDefault set the minimum level
 func SetLevel(lvl int) {
-	// loggerDefault.level = lvl
+	loggerDefault.SetLevel(lvl)
+}
+
+// GetLevel loggerDefault get the minimum level
+func GetLevel() int {
+	return loggerDefault.Level()
 }
 
 // SetLayout loggerDefault set the time format layout
 func SetLayout(layout string) {
-	loggerDefault.layout = layout
+	loggerDefault.SetLayout(layout)
+}
+
+// Trace loggerDefault deliver record to writer
+func Trace(fmt string, args ...interface{}) {
+	loggerDefault.deliverRecordToWriter(TRACE, fmt, args...)
 }
 
 // Debug loggerDefault deliver record to writer
@@ -288,9 +952,83 @@ func Error(fmt string, args ...interface{}) {
 	loggerDefault.deliverRecordToWriter(ERROR, fmt, args...)
 }
 
-// Fatal loggerDefault deliver record to writer
+// Panic loggerDefault deliver record to writer, flush the writers then panic with the message
+func Panic(fmt string, args ...interface{}) {
+	msg := formatMessage(fmt, args)
+	loggerDefault.deliverRecordToWriter(PANIC, "%s", msg)
+	loggerDefault.Flush()
+	panic(msg)
+}
+
+// Fatal loggerDefault deliver record to writer, close the logger then exit the process
 func Fatal(fmt string, args ...interface{}) {
+	deadline, cancel := loggerDefault.fatalDeadline()
 	loggerDefault.deliverRecordToWriter(FATAL, fmt, args...)
+	loggerDefault.exit(deadline, cancel)
+}
+
+// Flush loggerDefault wait until the queued records are written, then flush the writers
+func Flush() {
+	loggerDefault.Flush()
+}
+
+// With loggerDefault create a child logger with key/value pairs
+func With(kv ...interface{}) *Logger {
+	return loggerDefault.With(kv...)
+}
+
+// Tracew loggerDefault deliver record with key/value pairs to writer
+func Tracew(msg string, kv ...interface{}) {
+	loggerDefault.deliverFieldsToWriter(TRACE, msg, kv)
+}
+
+// Debugw loggerDefault deliver record with key/value pairs to writer
+func Debugw(msg string, kv ...interface{}) {
+	loggerDefault.deliverFieldsToWriter(DEBUG, msg, kv)
+}
+
+// Infow loggerDefault deliver record with key/value pairs to writer
+func Infow(msg string, kv ...interface{}) {
+	loggerDefault.deliverFieldsToWriter(INFO, msg, kv)
+}
+
+// Warnw loggerDefault deliver record with key/value pairs to writer
+func Warnw(msg string, kv ...interface{}) {
+	loggerDefault.deliverFieldsToWriter(WARNING, msg, kv)
+}
+
+// Errorw loggerDefault deliver record with key/value pairs to writer
+func Errorw(msg string, kv ...interface{}) {
+	loggerDefault.deliverFieldsToWriter(ERROR, msg, kv)
+}
+
+// Panicw loggerDefault deliver record with key/value pairs to writer, flush then panic
+func Panicw(msg string, kv ...interface{}) {
+	loggerDefault.deliverFieldsToWriter(PANIC, msg, kv)
+	loggerDefault.Flush()
+	panic(msg)
+}
+
+// Fatalw loggerDefault deliver record with key/value pairs to writer, close the logger then exit
+func Fatalw(msg string, kv ...interface{}) {
+	deadline, cancel := loggerDefault.fatalDeadline()
+	loggerDefault.deliverFieldsToWriter(FATAL, msg, kv)
+	loggerDefault.exit(deadline, cancel)
+}
+
+// SetOverflowPolicy loggerDefault set what to do when the tunnel is full
+func SetOverflowPolicy(p OverflowPolicy, level int) {
+	loggerDefault.SetOverflowPolicy(p, level)
+}
+
+// SetTunnelSize loggerDefault set the tunnel size, before the first record
+func SetTunnelSize(size int) error {
+	return loggerDefault.SetTunnelSize(size)
+}
+
+// Dropped loggerDefault number of records dropped by the overflow policy
+func Dropped() map[string]uint64 {
+	return loggerDefault.Dropped()
 }
 
 // Register loggerDefault register writer
@@ -298,14 +1036,29 @@ func Register(w Writer) {
 	loggerDefault.Register(w)
 }
 
+// Unregister loggerDefault remove the writer, it is flushed and closed
+func Unregister(w Writer) bool {
+	return loggerDefault.Unregister(w)
+}
+
+// Replace loggerDefault put w in place of old, old is flushed and closed
+func Replace(old, w Writer) error {
+	return loggerDefault.Replace(old, w)
+}
+
 // Close loggerDefault close logger
 func Close() {
 	loggerDefault.Close()
 }
 
+// CloseContext loggerDefault close logger, give up the queued records when ctx is done
+func CloseContext(ctx context.Context) (lost int, err error) {
+	return loggerDefault.CloseContext(ctx)
+}
+
 // ShowFullPath loggerDefault show full path
 func ShowFullPath(show bool) {
-	loggerDefault.fullPath = show
+	loggerDefault.ShowFullPath(show)
 }
 
 func init() {
@@ -0,0 +1,181 @@
+// Package log4gotest helpers to test code logging with log4go
+package log4gotest
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kdpujie/log4go"
+)
+
+// Entry copy of a written record
+type Entry struct {
+	Time    time.Time
+	Level   int
+	Code    string
+	Message string
+	Fields  []log4go.Field
+	Stack   []log4go.StackFrame
+	Err     error
+	Logger  string // name of the logger, empty for the root
+}
+
+// Field value of the last field with the key
+func (e Entry) Field(key string) (interface{}, bool) {
+	for i := len(e.Fields) - 1; i >= 0; i-- {
+		if e.Fields[i].Key == key {
+			return e.Fields[i].Value, true
+		}
+	}
+	return nil, false
+}
+
+// String "LEVEL code message k=v"
+func (e Entry) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s %s", log4go.LevelFlags[e.Level], e.Code, e.Message)
+	for _, f := range e.Fields {
+		b.WriteByte(' ')
+		b.WriteString(f.String())
+	}
+	return b.String()
+}
+
+// CaptureWriter writer keeping the records in memory, synchronously
+type CaptureWriter struct {
+	mu      sync.Mutex
+	entries []Entry
+	flushes int
+	logger  *log4go.Logger // flushed before reading the records, if set
+}
+
+// NewCaptureWriter create capture writer, register it on a logger to use it
+func NewCaptureWriter() *CaptureWriter {
+	return &CaptureWriter{}
+}
+
+// NewLogger create a sync mode logger writing every level to a capture writer,
+// a record is captured before the log call returns. The logger is closed when
+// the test ends.
+func NewLogger(t testing.TB) (*log4go.Logger, *CaptureWriter) {
+	l := log4go.NewLogger(log4go.WithSyncMode(-1))
+	l.SetLevel(log4go.TRACE)
+	c := NewCaptureWriter()
+	c.logger = l
+	l.Register(c)
+	t.Cleanup(l.Close)
+	return l, c
+}
+
+// Init capture writer init
+func (c *CaptureWriter) Init() error {
+	return nil
+}
+
+// Write copy the record
+func (c *CaptureWriter) Write(r *log4go.Record) error {
+	e := Entry{
+		Time:    r.Time(),
+		Level:   r.Level(),
+		Code:    r.Code(),
+		Message: r.Message(),
+		Fields:  append([]log4go.Field(nil), r.Fields()...),
+		Stack:   append([]log4go.StackFrame(nil), r.Stack()...),
+		Err:     r.Err(),
+		Logger:  r.LoggerName(),
+	}
+	c.mu.Lock()
+	c.entries = append(c.entries, e)
+	c.mu.Unlock()
+	return nil
+}
+
+// Flush count the flushes
+func (c *CaptureWriter) Flush() error {
+	c.mu.Lock()
+	c.flushes++
+	c.mu.Unlock()
+	return nil
+}
+
+// Flushes number of Flush calls
+func (c *CaptureWriter) Flushes() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.flushes
+}
+
+// Records the written records, in order
+func (c *CaptureWriter) Records() []Entry {
+	if c.logger != nil {
+		c.logger.Flush()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Entry(nil), c.entries...)
+}
+
+// Reset forget the written records
+func (c *CaptureWriter) Reset() {
+	if c.logger != nil {
+		c.logger.Flush()
+	}
+	c.mu.Lock()
+	c.entries = nil
+	c.mu.Unlock()
+}
+
+// Find the records at level whose message contains substring
+func (c *CaptureWriter) Find(level int, substring string) []Entry {
+	var found []Entry
+	for _, e := range c.Records() {
+		if e.Level == level && strings.Contains(e.Message, substring) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+// AssertLogged fail the test if no record at level contains substring
+func (c *CaptureWriter) AssertLogged(t testing.TB, level int, substring string) bool {
+	t.Helper()
+	if len(c.Find(level, substring)) > 0 {
+		return true
+	}
+	t.Errorf("no %s record containing %q, got:\n%s", log4go.LevelFlags[level], substring, c.dump())
+	return false
+}
+
+// AssertNotLogged fail the test if a record at level contains substring
+func (c *CaptureWriter) AssertNotLogged(t testing.TB, level int, substring string) bool {
+	t.Helper()
+	found := c.Find(level, substring)
+	if len(found) == 0 {
+		return true
+	}
+	t.Errorf("unexpected %s record containing %q: %s", log4go.LevelFlags[level], substring, found[0])
+	return false
+}
+
+// AssertCount fail the test if the number of records is not n
+func (c *CaptureWriter) AssertCount(t testing.TB, n int) bool {
+	t.Helper()
+	if got := len(c.Records()); got != n {
+		t.Errorf("got %d records, want %d:\n%s", got, n, c.dump())
+		return false
+	}
+	return true
+}
+
+func (c *CaptureWriter) dump() string {
+	var b strings.Builder
+	for _, e := range c.Records() {
+		b.WriteString("\t")
+		b.WriteString(e.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
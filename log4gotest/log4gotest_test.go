@@ -0,0 +1,121 @@
+package log4gotest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/kdpujie/log4go"
+)
+
+// recordingT testing.TB keeping the errors instead of failing the test
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestNewLoggerCapturesInTheLogCall(t *testing.T) {
+	l, c := NewLogger(t)
+	l.Trace("trace %d", 1)
+	l.Infow("user login", "user", "alice", "uid", 7)
+	l.With("req", "r1").Err(errors.New("timeout"), "query failed")
+
+	// captured without Flush, the logger is in sync mode
+	c.mu.Lock()
+	n := len(c.entries)
+	c.mu.Unlock()
+	if n != 3 {
+		t.Fatalf("captured %d records before Flush, want 3", n)
+	}
+
+	records := c.Records()
+	if records[0].Level != log4go.TRACE || records[0].Message != "trace 1" {
+		t.Errorf("records[0] = %s", records[0])
+	}
+	if v, ok := records[1].Field("uid"); !ok || v != 7 {
+		t.Errorf("uid = %v, %v", v, ok)
+	}
+	if _, ok := records[1].Field("missing"); ok {
+		t.Error("missing field found")
+	}
+	if v, _ := records[2].Field("req"); v != "r1" {
+		t.Errorf("req = %v", v)
+	}
+	if records[2].Err == nil || records[2].Err.Error() != "timeout" {
+		t.Errorf("err = %v", records[2].Err)
+	}
+	if !strings.HasPrefix(records[1].String(), "INFO ") || !strings.HasSuffix(records[1].String(), "user login user=alice uid=7") {
+		t.Errorf("String() = %s", records[1].String())
+	}
+}
+
+func TestCaptureWriterFind(t *testing.T) {
+	l, c := NewLogger(t)
+	l.Info("order 1 paid")
+	l.Warn("order 2 late")
+	l.Info("order 3 paid")
+
+	if got := len(c.Find(log4go.INFO, "paid")); got != 2 {
+		t.Errorf("found %d INFO paid, want 2", got)
+	}
+	if got := len(c.Find(log4go.WARNING, "paid")); got != 0 {
+		t.Errorf("found %d WARN paid, want 0", got)
+	}
+
+	c.Reset()
+	if got := len(c.Records()); got != 0 {
+		t.Errorf("%d records after Reset", got)
+	}
+}
+
+func TestCaptureWriterAsserts(t *testing.T) {
+	l, c := NewLogger(t)
+	l.Info("started")
+
+	rt := &recordingT{TB: t}
+	if !c.AssertLogged(rt, log4go.INFO, "start") {
+		t.Error("AssertLogged failed on a logged record")
+	}
+	if c.AssertLogged(rt, log4go.ERROR, "start") {
+		t.Error("AssertLogged passed at the wrong level")
+	}
+	if !c.AssertNotLogged(rt, log4go.ERROR, "start") {
+		t.Error("AssertNotLogged failed on a missing record")
+	}
+	if c.AssertNotLogged(rt, log4go.INFO, "start") {
+		t.Error("AssertNotLogged passed on a logged record")
+	}
+	if !c.AssertCount(rt, 1) || c.AssertCount(rt, 2) {
+		t.Error("AssertCount")
+	}
+
+	if len(rt.errors) != 3 {
+		t.Fatalf("errors = %q, want 3", rt.errors)
+	}
+	if !strings.Contains(rt.errors[0], "INFO") || !strings.Contains(rt.errors[0], "started") {
+		t.Errorf("AssertLogged error does not list the records: %s", rt.errors[0])
+	}
+}
+
+func TestCaptureWriterOnAsyncLogger(t *testing.T) {
+	l := log4go.NewLogger(log4go.WithTunnelSize(16))
+	defer l.Close()
+	c := NewCaptureWriter()
+	l.Register(c)
+
+	l.Info("queued")
+	l.Flush()
+	if got := len(c.Records()); got != 1 {
+		t.Fatalf("%d records after Flush, want 1", got)
+	}
+	if c.Flushes() == 0 {
+		t.Error("Flush not counted")
+	}
+}
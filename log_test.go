@@ -0,0 +1,443 @@
+package log4go
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// memWriter keep the written records in memory
+type memWriter struct {
+	mu      sync.Mutex
+	records []Record
+}
+
+func (w *memWriter) Init() error {
+	return nil
+}
+
+func (w *memWriter) Write(r *Record) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var c Record
+	c.copyFrom(r)
+	w.records = append(w.records, c)
+	return nil
+}
+
+// messages the messages of the written records
+func (w *memWriter) messages() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	msgs := make([]string, len(w.records))
+	for i := range w.records {
+		msgs[i] = w.records[i].info
+	}
+	return msgs
+}
+
+// last the last written record, the zero record if none
+func (w *memWriter) last() Record {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.records) == 0 {
+		return Record{}
+	}
+	return w.records[len(w.records)-1]
+}
+
+// newTestLogger create logger writing to a memWriter, closed when the test ends
+func newTestLogger(t *testing.T, opts ...LoggerOption) (*Logger, *memWriter) {
+	t.Helper()
+	l := NewLogger(append([]LoggerOption{WithTunnelSize(tunnelSizeDefault)}, opts...)...)
+	l.SetLevel(TRACE)
+	w := &memWriter{}
+	l.Register(w)
+	t.Cleanup(l.Close)
+	return l, w
+}
+
+func TestSetTunnelSize(t *testing.T) {
+	l, w := newTestLogger(t)
+	if err := l.SetTunnelSize(0); err == nil {
+		t.Error("size 0 accepted")
+	}
+	if err := l.SetTunnelSize(16); err != nil {
+		t.Fatal(err)
+	}
+
+	l.Info("first")
+	l.Flush()
+	if got := cap(l.tunnel); got != 16 {
+		t.Fatalf("tunnel size = %d, want 16", got)
+	}
+	if err := l.SetTunnelSize(16); err != nil {
+		t.Errorf("same size rejected: %v", err)
+	}
+	if err := l.SetTunnelSize(32); err == nil {
+		t.Error("size changed after the first record")
+	}
+	if got := strings.Join(w.messages(), ","); got != "first" {
+		t.Errorf("messages = %s", got)
+	}
+}
+
+func TestSetupLogInvalidTunnelSize(t *testing.T) {
+	if err := SetupLog(LogConfig{TunnelSize: -1}); err == nil {
+		t.Fatal("negative tunnel_size accepted")
+	}
+}
+
+// stalledLogger logger whose writer blocks until the test ends, the tunnel is
+// full and a log call is blocked on it
+func stalledLogger(t *testing.T) (*Logger, chan struct{}) {
+	t.Helper()
+	w := &blockingWriter{release: make(chan struct{})}
+	l := NewLogger(WithTunnelSize(1))
+	l.Register(w)
+	t.Cleanup(func() { close(w.release) })
+
+	l.Info("taken by the writer goroutine")
+	for atomic.LoadInt32(&w.writing) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	l.Info("fill the tunnel")
+	blocked := make(chan struct{})
+	go func() {
+		l.Info("blocked on the full tunnel")
+		close(blocked)
+	}()
+	return l, blocked
+}
+
+func TestCloseContextDeadlineWithStalledWriter(t *testing.T) {
+	l, blocked := stalledLogger(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	lost, err := l.CloseContext(ctx)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("CloseContext returned after %v", elapsed)
+	}
+	if lost == 0 {
+		t.Error("lost = 0, the tunnel was full")
+	}
+	select {
+	case <-blocked:
+	case <-time.After(time.Second):
+		t.Fatal("the blocked log call did not return")
+	}
+}
+
+func TestFatalExitsWithStalledWriter(t *testing.T) {
+	l, _ := stalledLogger(t)
+
+	exitFunc, timeout := ExitFunc, FatalCloseTimeout
+	defer func() { ExitFunc, FatalCloseTimeout = exitFunc, timeout }()
+	code := make(chan int, 1)
+	ExitFunc = func(c int) { code <- c }
+	FatalCloseTimeout = 100 * time.Millisecond
+
+	go l.Fatal("fatal")
+	select {
+	case c := <-code:
+		if c != 1 {
+			t.Fatalf("exit code = %d, want 1", c)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Fatal did not exit")
+	}
+}
+
+// fullTunnelLogger logger whose writer holds "first" until release is closed
+// and whose tunnel of 2 records is full
+func fullTunnelLogger(t *testing.T, p OverflowPolicy, level int) (*Logger, *blockingWriter) {
+	t.Helper()
+	w := &blockingWriter{release: make(chan struct{})}
+	l := NewLogger(WithTunnelSize(2), WithOverflowPolicy(p, level))
+	l.Register(w)
+	t.Cleanup(l.Close)
+
+	l.Info("first")
+	for atomic.LoadInt32(&w.writing) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	l.Info("a")
+	l.Info("b")
+	return l, w
+}
+
+func TestOverflowDropNewest(t *testing.T) {
+	l, w := fullTunnelLogger(t, OverflowDropNewest, 0)
+	l.Info("c")
+	l.Warn("d")
+	if got := l.Dropped(); got["INFO"] != 1 || got["WARN"] != 1 {
+		t.Fatalf("dropped = %v", got)
+	}
+	close(w.release)
+	l.Flush()
+
+	want := "first,a,b,2 records dropped, tunnel was full (INFO=1 WARN=1)"
+	if got := strings.Join(w.messages(), ","); got != want {
+		t.Fatalf("messages = %s, want %s", got, want)
+	}
+}
+
+func TestOverflowDropOldest(t *testing.T) {
+	l, w := fullTunnelLogger(t, OverflowDropOldest, 0)
+	l.Info("c")
+	l.Info("d")
+	if got := l.Dropped()["INFO"]; got != 2 {
+		t.Fatalf("dropped INFO = %d, want 2", got)
+	}
+	close(w.release)
+	l.Flush()
+
+	want := "first,c,d,2 records dropped, tunnel was full (INFO=2)"
+	if got := strings.Join(w.messages(), ","); got != want {
+		t.Fatalf("messages = %s, want %s", got, want)
+	}
+}
+
+func TestOverflowDropOldestFullOfBarriers(t *testing.T) {
+	w := &blockingWriter{release: make(chan struct{})}
+	l := NewLogger(WithTunnelSize(2), WithOverflowPolicy(OverflowDropOldest, 0))
+	l.Register(w)
+	t.Cleanup(l.Close)
+
+	l.Info("first")
+	for atomic.LoadInt32(&w.writing) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	flushed := make(chan struct{}, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			l.Flush()
+			flushed <- struct{}{}
+		}()
+	}
+	for len(l.tunnel) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	logged := make(chan struct{})
+	go func() {
+		l.Info("c")
+		close(logged)
+	}()
+	select {
+	case <-logged:
+	case <-time.After(time.Second):
+		t.Fatal("Info spins on a tunnel full of barriers")
+	}
+	if got := l.Dropped()["INFO"]; got != 1 {
+		t.Fatalf("dropped INFO = %d, want 1", got)
+	}
+
+	close(w.release)
+	<-flushed
+	<-flushed
+	want := "first,1 records dropped, tunnel was full (INFO=1)"
+	l.Flush()
+	if got := strings.Join(w.messages(), ","); got != want {
+		t.Fatalf("messages = %s, want %s", got, want)
+	}
+}
+
+func TestOverflowDropBelow(t *testing.T) {
+	l, w := fullTunnelLogger(t, OverflowDropBelow, WARNING)
+	l.Info("c")
+	blocked := make(chan struct{})
+	go func() {
+		l.Error("e")
+		close(blocked)
+	}()
+	select {
+	case <-blocked:
+		t.Fatal("ERROR dropped or queued, the tunnel is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(w.release)
+	<-blocked
+	l.Flush()
+
+	if got := l.Dropped(); got["INFO"] != 1 || got["ERROR"] != 0 {
+		t.Fatalf("dropped = %v", got)
+	}
+	if got := strings.Join(w.messages(), ","); !strings.HasPrefix(got, "first,a,b,e") {
+		t.Fatalf("messages = %s", got)
+	}
+}
+
+func TestParseOverflowPolicy(t *testing.T) {
+	for name, want := range map[string]OverflowPolicy{
+		"block":        OverflowBlock,
+		" Drop_Newest": OverflowDropNewest,
+		"drop_oldest":  OverflowDropOldest,
+		"drop_below":   OverflowDropBelow,
+	} {
+		if p, ok := ParseOverflowPolicy(name); !ok || p != want {
+			t.Errorf("ParseOverflowPolicy(%q) = %s, %v", name, p, ok)
+		}
+		if want.String() != strings.ToLower(strings.TrimSpace(name)) {
+			t.Errorf("%d.String() = %s", want, want.String())
+		}
+	}
+	if _, ok := ParseOverflowPolicy("drop"); ok {
+		t.Error("ParseOverflowPolicy(drop) ok")
+	}
+}
+
+// closerWriter memWriter counting the Close calls
+type closerWriter struct {
+	memWriter
+	closed int32
+}
+
+func (w *closerWriter) Close() error {
+	atomic.AddInt32(&w.closed, 1)
+	return nil
+}
+
+func TestCloseDrainsAndIsIdempotent(t *testing.T) {
+	l := NewLogger(WithTunnelSize(tunnelSizeDefault))
+	w := &closerWriter{}
+	l.Register(w)
+	for i := 0; i < 100; i++ {
+		l.Info("queued")
+	}
+
+	lost, err := l.CloseContext(context.Background())
+	if lost != 0 || err != nil {
+		t.Fatalf("CloseContext = %d, %v", lost, err)
+	}
+	if got := len(w.messages()); got != 100 {
+		t.Fatalf("%d records written, want 100", got)
+	}
+	l.Close()
+	if lost, err := l.CloseContext(context.Background()); lost != 0 || err != nil {
+		t.Fatalf("second CloseContext = %d, %v", lost, err)
+	}
+	if n := atomic.LoadInt32(&w.closed); n != 1 {
+		t.Fatalf("writer closed %d times, want 1", n)
+	}
+
+	// written to stderr, not to the closed writers
+	l.Info("after close")
+	l.Flush()
+	if got := len(w.messages()); got != 100 {
+		t.Fatalf("%d records written after close", got)
+	}
+}
+
+// initErrWriter writer whose Init fails
+type initErrWriter struct {
+	closerWriter
+}
+
+func (w *initErrWriter) Init() error {
+	return errors.New("init failed")
+}
+
+func TestUnregisterAndReplace(t *testing.T) {
+	tests := []struct {
+		name string
+		// change the writers of l, a and b are registered, c is not
+		change  func(l *Logger, a, b, c *closerWriter) error
+		wantErr bool
+		// messages of a, b, c after logging "before", the change and "after"
+		want   [3]string
+		closed [3]int32
+	}{
+		{"unregister", func(l *Logger, a, b, c *closerWriter) error {
+			if !l.Unregister(a) {
+				return errors.New("not unregistered")
+			}
+			return nil
+		}, false, [3]string{"before", "before,after", ""}, [3]int32{1, 0, 0}},
+		{"unregister unknown", func(l *Logger, a, b, c *closerWriter) error {
+			if l.Unregister(c) {
+				return errors.New("unregistered")
+			}
+			return nil
+		}, false, [3]string{"before,after", "before,after", ""}, [3]int32{0, 0, 0}},
+		{"replace", func(l *Logger, a, b, c *closerWriter) error {
+			return l.Replace(a, c)
+		}, false, [3]string{"before", "before,after", "after"}, [3]int32{1, 0, 0}},
+		{"replace unknown", func(l *Logger, a, b, c *closerWriter) error {
+			return l.Replace(c, a)
+		}, true, [3]string{"before,after", "before,after", ""}, [3]int32{0, 0, 0}},
+		{"replace init error", func(l *Logger, a, b, c *closerWriter) error {
+			return l.Replace(a, &initErrWriter{})
+		}, true, [3]string{"before,after", "before,after", ""}, [3]int32{0, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLogger(WithTunnelSize(tunnelSizeDefault))
+			t.Cleanup(l.Close)
+			ws := [3]*closerWriter{{}, {}, {}}
+			l.Register(ws[0])
+			l.Register(ws[1])
+
+			l.Info("before")
+			l.Flush()
+			if err := tt.change(l, ws[0], ws[1], ws[2]); (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v", err)
+			}
+			l.Info("after")
+			l.Flush()
+			for i, w := range ws {
+				if got := strings.Join(w.messages(), ","); got != tt.want[i] {
+					t.Errorf("writer %d messages = %s, want %s", i, got, tt.want[i])
+				}
+				if n := atomic.LoadInt32(&w.closed); n != tt.closed[i] {
+					t.Errorf("writer %d closed %d times, want %d", i, n, tt.closed[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReplaceWhileLogging(t *testing.T) {
+	l := NewLogger(WithTunnelSize(16))
+	t.Cleanup(l.Close)
+	ws := []*closerWriter{{}}
+	l.Register(ws[0])
+
+	const n = 2000
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < n; i++ {
+			l.Info("msg")
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		w := &closerWriter{}
+		if err := l.Replace(ws[len(ws)-1], w); err != nil {
+			t.Fatal(err)
+		}
+		ws = append(ws, w)
+	}
+	<-done
+	l.Flush()
+
+	total := 0
+	for i, w := range ws {
+		total += len(w.messages())
+		if closed := atomic.LoadInt32(&w.closed); (i < len(ws)-1) != (closed == 1) {
+			t.Errorf("writer %d closed %d times", i, closed)
+		}
+	}
+	if total != n {
+		t.Fatalf("%d records written, want %d", total, n)
+	}
+}
@@ -0,0 +1,148 @@
+package log4go
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// OverflowPolicy what to do with a record when the tunnel is full
+type OverflowPolicy int32
+
+const (
+	// OverflowBlock wait until the tunnel has room, the default
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest drop the record being logged
+	OverflowDropNewest
+	// OverflowDropOldest drop the oldest record in the tunnel to make room,
+	// the record being logged if the oldest is waited on by a Flush
+	OverflowDropOldest
+	// OverflowDropBelow drop the record below the overflow level, block for the others
+	OverflowDropBelow
+)
+
+var overflowPolicyNames = [...]string{"block", "drop_newest", "drop_oldest", "drop_below"}
+
+// String policy name
+func (p OverflowPolicy) String() string {
+	if p < 0 || int(p) >= len(overflowPolicyNames) {
+		return "unknown"
+	}
+	return overflowPolicyNames[p]
+}
+
+// ParseOverflowPolicy parse the policy name, block, drop_newest, drop_oldest or drop_below
+func ParseOverflowPolicy(name string) (OverflowPolicy, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for i, n := range overflowPolicyNames {
+		if n == name {
+			return OverflowPolicy(i), true
+		}
+	}
+	return OverflowBlock, false
+}
+
+// overflow drop counters, per level
+type overflowStats struct {
+	dropped [len(LevelFlags)]uint64 // since the logger was created
+	pending [len(LevelFlags)]uint64 // not yet reported by a dropped record
+	any     uint32                  // set when pending is not zero
+}
+
+// SetOverflowPolicy Logger set what to do when the tunnel is full, level is
+// used by OverflowDropBelow. It is safe to call while logging.
+func (l *Logger) SetOverflowPolicy(p OverflowPolicy, level int) {
+	atomic.StoreInt32(&l.overflowLevel, int32(level))
+	atomic.StoreInt32(&l.overflow, int32(p))
+}
+
+// OverflowPolicy Logger get the overflow policy
+func (l *Logger) OverflowPolicy() OverflowPolicy {
+	return OverflowPolicy(atomic.LoadInt32(&l.overflow))
+}
+
+// Dropped Logger number of records dropped by the overflow policy, per level name
+func (l *Logger) Dropped() map[string]uint64 {
+	dropped := make(map[string]uint64, len(LevelFlags))
+	for i, f := range LevelFlags {
+		dropped[f] = atomic.LoadUint64(&l.drops.dropped[i])
+	}
+	return dropped
+}
+
+// send put the record into the tunnel following the overflow policy, false
+// if the logger is closing while the tunnel is full, the record is not taken
+func (l *Logger) send(r *Record) bool {
+	p := OverflowPolicy(atomic.LoadInt32(&l.overflow))
+	if p == OverflowBlock || (p == OverflowDropBelow && int32(r.level) >= atomic.LoadInt32(&l.overflowLevel)) {
+		select {
+		case l.tunnel <- r:
+			return true
+		case <-l.closing:
+			return false
+		}
+	}
+
+	for {
+		select {
+		case l.tunnel <- r:
+			return true
+		default:
+		}
+
+		switch p {
+		case OverflowDropOldest:
+			select {
+			case old := <-l.tunnel:
+				if old.barrier != nil {
+					// a Flush is waiting on it, put it back and drop the
+					// new record, the tunnel may be full of barriers
+					select {
+					case l.tunnel <- old:
+					case <-l.closing:
+						return false
+					}
+					l.drop(r)
+					return true
+				}
+				l.drop(old)
+			default:
+			}
+		default:
+			l.drop(r)
+			return true
+		}
+	}
+}
+
+func (l *Logger) drop(r *Record) {
+	atomic.AddUint64(&l.drops.dropped[r.level], 1)
+	atomic.AddUint64(&l.drops.pending[r.level], 1)
+	atomic.StoreUint32(&l.drops.any, 1)
+	recordPool.Put(r)
+}
+
+// reportDropped write a record to tell how many records were dropped, called by
+// the writer goroutine once the tunnel is drained
+func (l *Logger) reportDropped() {
+	if atomic.LoadUint32(&l.drops.any) == 0 || len(l.tunnel) > 0 {
+		return
+	}
+	atomic.StoreUint32(&l.drops.any, 0)
+
+	var total uint64
+	var detail strings.Builder
+	for i, f := range LevelFlags {
+		if n := atomic.SwapUint64(&l.drops.pending[i], 0); n > 0 {
+			total += n
+			fmt.Fprintf(&detail, " %s=%d", f, n)
+		}
+	}
+	if total == 0 {
+		return
+	}
+
+	r := l.newRecord(WARNING, "log4go", fmt.Sprintf("%d records dropped, tunnel was full (%s)", total, strings.TrimSpace(detail.String())))
+	l.writeToWriters(r)
+	recordPool.Put(r)
+}
@@ -0,0 +1,312 @@
+package log4go
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// MaskMode how a sensitive value is masked
+type MaskMode int
+
+const (
+	MaskFull    MaskMode = iota // every character becomes *
+	MaskPartial                 // the last 4 characters are kept
+	MaskHash                    // replaced by sha256:<16 hex>
+)
+
+// ParseMaskMode parse full, partial or hash, empty is full
+func ParseMaskMode(s string) (MaskMode, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "full":
+		return MaskFull, true
+	case "partial":
+		return MaskPartial, true
+	case "hash":
+		return MaskHash, true
+	}
+	return MaskFull, false
+}
+
+// mask the value following the mode
+func (m MaskMode) mask(v string) string {
+	switch m {
+	case MaskPartial:
+		n := utf8.RuneCountInString(v)
+		if n <= 4 {
+			return strings.Repeat("*", n)
+		}
+		i := len(v)
+		for k := 0; k < 4; k++ {
+			_, size := utf8.DecodeLastRuneInString(v[:i])
+			i -= size
+		}
+		return strings.Repeat("*", n-4) + v[i:]
+	case MaskHash:
+		sum := sha256.Sum256([]byte(v))
+		return "sha256:" + hex.EncodeToString(sum[:8])
+	}
+	return strings.Repeat("*", utf8.RuneCountInString(v))
+}
+
+// RedactRule a sensitive value to mask: the match of the regexp, or its first
+// group if it has one. If Valid is set, only the values it accepts are masked.
+// The numeric field values are only redacted by the Numeric rules, a masked
+// number becomes a string.
+type RedactRule struct {
+	Name    string
+	Re      *regexp.Regexp
+	Mask    MaskMode
+	Valid   func(string) bool
+	Numeric bool
+
+	hits uint64 // accessed atomically
+}
+
+// built-in detectors, in the order they are applied
+var redactDetectors = []struct {
+	name    string
+	expr    string
+	valid   func(string) bool
+	numeric bool // digits only, applied to the numeric field values too
+}{
+	{"bearer", `(?i)\bbearer\s+([A-Za-z0-9\-._~+/]+=*)`, nil, false},
+	{"email", `[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`, nil, false},
+	{"cn_id", `\b\d{17}[\dXx]\b`, validCNID, true},
+	// grouped by 4 (or 4-6-5), else a known IIN prefix, so ids and timestamps are not taken for cards
+	{"credit_card", `\b(?:\d{4}(?:[ -]\d{4}){3}(?:[ -]\d{3})?|\d{4}[ -]\d{6}[ -]\d{5}|` +
+		`4\d{12}(?:\d{3})?|5[1-5]\d{14}|2[2-7]\d{14}|3[47]\d{13}|6(?:011|5\d{2})\d{12}|62\d{14,17}|35\d{14})\b`, validLuhn, true},
+	{"cn_mobile", `\b1[3-9]\d{9}\b`, nil, true},
+}
+
+// RedactDetectors names of the built-in detectors
+func RedactDetectors() []string {
+	names := make([]string, len(redactDetectors))
+	for i, d := range redactDetectors {
+		names[i] = d.name
+	}
+	return names
+}
+
+// NewDetectorRule create the rule of a built-in detector: bearer, email,
+// cn_id, credit_card (Luhn checked) or cn_mobile. The digit detectors, cn_id,
+// credit_card and cn_mobile, also mask the numeric field values.
+func NewDetectorRule(name string, mask MaskMode) (*RedactRule, error) {
+	for _, d := range redactDetectors {
+		if d.name == name {
+			return &RedactRule{Name: name, Re: regexp.MustCompile(d.expr), Mask: mask, Valid: d.valid, Numeric: d.numeric}, nil
+		}
+	}
+	return nil, errors.New("unknown redact detector (" + name + ")")
+}
+
+// NewRedactRule create a rule masking the matches of expr, or their first group
+func NewRedactRule(name, expr string, mask MaskMode) (*RedactRule, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("redact rule %s: %v", name, err)
+	}
+	return &RedactRule{Name: name, Re: re, Mask: mask}, nil
+}
+
+// redact mask the values of the rule in s
+func (rule *RedactRule) redact(s string) string {
+	matches := rule.Re.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s
+	}
+	var b strings.Builder
+	prev := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if len(m) >= 4 && m[2] >= 0 {
+			start, end = m[2], m[3]
+		}
+		v := s[start:end]
+		if rule.Valid != nil && !rule.Valid(v) {
+			continue
+		}
+		b.WriteString(s[prev:start])
+		b.WriteString(rule.Mask.mask(v))
+		prev = end
+		atomic.AddUint64(&rule.hits, 1)
+	}
+	if prev == 0 {
+		return s
+	}
+	b.WriteString(s[prev:])
+	return b.String()
+}
+
+// Redactor mask the sensitive values of the message and the field values of
+// the records before they are written
+type Redactor struct {
+	rules []*RedactRule
+}
+
+// NewRedactor create redactor applying the rules in order
+func NewRedactor(rules ...*RedactRule) *Redactor {
+	return &Redactor{rules: rules}
+}
+
+// Hits number of masked values per rule name
+func (rd *Redactor) Hits() map[string]uint64 {
+	hits := make(map[string]uint64, len(rd.rules))
+	for _, rule := range rd.rules {
+		hits[rule.Name] += atomic.LoadUint64(&rule.hits)
+	}
+	return hits
+}
+
+// String mask the sensitive values of s
+func (rd *Redactor) String(s string) string {
+	for _, rule := range rd.rules {
+		s = rule.redact(s)
+	}
+	return s
+}
+
+// number mask the number formatted as s with the Numeric rules
+func (rd *Redactor) number(s string) string {
+	for _, rule := range rd.rules {
+		if rule.Numeric {
+			s = rule.redact(s)
+		}
+	}
+	return s
+}
+
+// redactRecord mask the message and the field values, a changed value becomes a string
+func (rd *Redactor) redactRecord(r *Record) {
+	r.info = rd.String(r.info)
+	for i := range r.fields {
+		f := &r.fields[i]
+		switch v := f.Value.(type) {
+		case nil, bool:
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr, float32, float64:
+			s := formatFieldValue(v)
+			if rs := rd.number(s); rs != s {
+				f.Value = rs
+			}
+		case errorChain:
+			var chain errorChain
+			for k, s := range v {
+				if rs := rd.String(s); rs != s {
+					if chain == nil {
+						chain = append(errorChain(nil), v...)
+					}
+					chain[k] = rs
+				}
+			}
+			if chain != nil {
+				f.Value = chain
+			}
+		default:
+			s := formatFieldValue(v)
+			if rs := rd.String(s); rs != s {
+				f.Value = rs
+			}
+		}
+	}
+}
+
+// NewRedactorWithConf create redactor of the config, nil if not enabled
+func NewRedactorWithConf(conf *ConfRedact) (*Redactor, error) {
+	if !conf.Enable {
+		return nil, nil
+	}
+	mask, ok := ParseMaskMode(conf.Mask)
+	if !ok {
+		return nil, errors.New("invalid redact mask (" + conf.Mask + ")")
+	}
+	detectors := conf.Detectors
+	if len(detectors) == 0 {
+		detectors = RedactDetectors()
+	}
+	rd := &Redactor{}
+	for _, name := range detectors {
+		rule, err := NewDetectorRule(strings.TrimSpace(name), mask)
+		if err != nil {
+			return nil, err
+		}
+		rd.rules = append(rd.rules, rule)
+	}
+	for _, rc := range conf.Rules {
+		ruleMask := mask
+		if rc.Mask != "" {
+			if ruleMask, ok = ParseMaskMode(rc.Mask); !ok {
+				return nil, errors.New("invalid redact mask (" + rc.Mask + ") of rule " + rc.Name)
+			}
+		}
+		rule, err := NewRedactRule(rc.Name, rc.Pattern, ruleMask)
+		if err != nil {
+			return nil, err
+		}
+		rule.Numeric = rc.Numeric
+		rd.rules = append(rd.rules, rule)
+	}
+	return rd, nil
+}
+
+// SetRedactor mask the sensitive values of the records with rd, after the
+// hooks and filters, nil disables it
+func (l *Logger) SetRedactor(rd *Redactor) {
+	l.lock.Lock()
+	l.redactor = rd
+	l.lock.Unlock()
+}
+
+// Redactor the redactor of the logger, nil if none
+func (l *Logger) Redactor() *Redactor {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return l.redactor
+}
+
+// validLuhn whether the digits of s pass the Luhn check
+func validLuhn(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && n <= 19 && sum%10 == 0
+}
+
+// validCNID whether s is an 18 digits chinese ID number with a valid check digit
+func validCNID(s string) bool {
+	if len(s) != 18 {
+		return false
+	}
+	weights := [17]int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	sum := 0
+	for i, w := range weights {
+		sum += int(s[i]-'0') * w
+	}
+	last := s[17]
+	if last == 'x' {
+		last = 'X'
+	}
+	return "10X98765432"[sum%11] == last
+}
+
+// SetRedactor loggerDefault mask the sensitive values of the records
+func SetRedactor(rd *Redactor) {
+	loggerDefault.SetRedactor(rd)
+}
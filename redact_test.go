@@ -0,0 +1,259 @@
+package log4go
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestRedactor(t *testing.T, mask MaskMode, names ...string) *Redactor {
+	t.Helper()
+	rd := NewRedactor()
+	for _, name := range names {
+		rule, err := NewDetectorRule(name, mask)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rd.rules = append(rd.rules, rule)
+	}
+	return rd
+}
+
+func TestRedactDetectors(t *testing.T) {
+	for _, tc := range []struct {
+		detector string
+		in, want string
+	}{
+		{"bearer", "Authorization: Bearer abc.def-123", "Authorization: Bearer ***********"},
+		{"bearer", "bearer", "bearer"},
+		{"email", "mail a.b@example.com now", "mail *************** now"},
+		{"email", "user@localhost", "user@localhost"},
+		{"cn_id", "id 11010519491231002X", "id ******************"},
+		{"cn_id", "id 110105194912310021", "id 110105194912310021"}, // check digit
+		{"credit_card", "card 4111 1111 1111 1111", "card *******************"},
+		{"credit_card", "card 4111-1111-1111-1111.", "card *******************."},
+		{"credit_card", "card 4111111111111111", "card ****************"},
+		{"credit_card", "amex 378282246310005", "amex ***************"},
+		{"credit_card", "amex 3782 822463 10005", "amex *****************"},
+		{"credit_card", "discover 6011111111111117", "discover ****************"},
+		{"credit_card", "unionpay 6212345678901232", "unionpay ****************"},
+		{"credit_card", "card 4111111111111112", "card 4111111111111112"}, // Luhn
+		{"credit_card", "order 9876543210987658", "order 9876543210987658"},
+		{"credit_card", "ts 1710000000000009", "ts 1710000000000009"},
+		{"credit_card", "id 1234567890123456785", "id 1234567890123456785"},
+		{"credit_card", "ms 1700000000000", "ms 1700000000000"},
+		{"cn_mobile", "tel 13812345678", "tel ***********"},
+		{"cn_mobile", "tel 12345678901", "tel 12345678901"},
+		{"cn_mobile", "no 138123456789", "no 138123456789"},
+	} {
+		rd := newTestRedactor(t, MaskFull, tc.detector)
+		if got := rd.String(tc.in); got != tc.want {
+			t.Errorf("%s(%q) = %q, want %q", tc.detector, tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestRedactNoFalsePositiveIDs(t *testing.T) {
+	// Luhn valid numbers which are no cards
+	for _, s := range []string{"9876543210987658", "1710000000000009", "1234567890123456785"} {
+		if !validLuhn(s) {
+			t.Fatalf("%s fails the Luhn check", s)
+		}
+	}
+}
+
+func TestMaskModes(t *testing.T) {
+	for _, tc := range []struct {
+		mode MaskMode
+		in   string
+		want string
+	}{
+		{MaskFull, "secret", "******"},
+		{MaskFull, "密码", "**"},
+		{MaskPartial, "4111111111111111", "************1111"},
+		{MaskPartial, "abcd", "****"},
+		{MaskPartial, "用户名字张三", "**名字张三"},
+		{MaskHash, "secret", "sha256:2bb80d537b1da3e3"},
+	} {
+		if got := tc.mode.mask(tc.in); got != tc.want {
+			t.Errorf("mask %d (%q) = %q, want %q", tc.mode, tc.in, got, tc.want)
+		}
+	}
+
+	for s, want := range map[string]MaskMode{"": MaskFull, "full": MaskFull, " Partial ": MaskPartial, "HASH": MaskHash} {
+		if got, ok := ParseMaskMode(s); !ok || got != want {
+			t.Errorf("ParseMaskMode(%q) = %d, %v", s, got, ok)
+		}
+	}
+	if _, ok := ParseMaskMode("half"); ok {
+		t.Error("ParseMaskMode(half) ok")
+	}
+}
+
+func TestValidLuhn(t *testing.T) {
+	for s, want := range map[string]bool{
+		"4111111111111111":     true,
+		"4111 1111 1111 1111":  true,
+		"378282246310005":      true,
+		"4111111111111112":     false,
+		"000000000000":         false, // too short
+		"00000000000000000000": false, // too long
+	} {
+		if got := validLuhn(s); got != want {
+			t.Errorf("validLuhn(%s) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestValidCNID(t *testing.T) {
+	for s, want := range map[string]bool{
+		"11010519491231002X": true,
+		"11010519491231002x": true,
+		"110105194912310021": false,
+		"11010519491231002":  false,
+	} {
+		if got := validCNID(s); got != want {
+			t.Errorf("validCNID(%s) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestRedactRecordFields(t *testing.T) {
+	rd := newTestRedactor(t, MaskFull, "email", "credit_card")
+	uid, err := NewRedactRule("uid", `^\d{8}$`, MaskHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uid.Numeric = true
+	rd.rules = append(rd.rules, uid)
+
+	r := &Record{info: "mail to a@b.cn"}
+	r.fields = []Field{
+		{Key: "email", Value: "a@b.cn"},
+		{Key: "card", Value: int64(4111111111111111)},
+		{Key: "order", Value: int64(9876543210987658)}, // Luhn valid, no card prefix
+		{Key: "amount", Value: 12.5},
+		{Key: "uid", Value: 12345678},
+		{Key: "ok", Value: true},
+		{Key: "nil", Value: nil},
+		{Key: ErrorChainKey, Value: errorChain{"send to a@b.cn", "timeout"}},
+	}
+	rd.redactRecord(r)
+
+	if r.info != "mail to ******" {
+		t.Errorf("info = %q", r.info)
+	}
+	want := []interface{}{"******", "****************", int64(9876543210987658), 12.5, uid.Mask.mask("12345678"), true, nil,
+		errorChain{"send to ******", "timeout"}}
+	for i, f := range r.fields {
+		if fmt.Sprintf("%T %v", f.Value, f.Value) != fmt.Sprintf("%T %v", want[i], want[i]) {
+			t.Errorf("%s = %T %v, want %T %v", f.Key, f.Value, f.Value, want[i], want[i])
+		}
+	}
+}
+
+func TestRedactorHits(t *testing.T) {
+	rd := newTestRedactor(t, MaskPartial, "email", "cn_mobile")
+	rd.String("a@b.cn c@d.cn 13812345678")
+	rd.String("nothing")
+	rd.redactRecord(&Record{info: "e@f.cn", fields: []Field{{Key: "tel", Value: "13912345678"}}})
+
+	hits := rd.Hits()
+	if hits["email"] != 3 || hits["cn_mobile"] != 2 || len(hits) != 2 {
+		t.Fatalf("hits = %v", hits)
+	}
+}
+
+func TestRedactLoggerRecords(t *testing.T) {
+	l, w := newTestLogger(t)
+	l.SetRedactor(newTestRedactor(t, MaskPartial, "credit_card"))
+	l.Infow("paid with 4111 1111 1111 1111", "card", "4111111111111111", "ts", int64(1710000000000009))
+	l.Flush()
+
+	r := w.records[0]
+	if r.info != "paid with ***************1111" {
+		t.Errorf("info = %q", r.info)
+	}
+	if r.fields[0].Value != "************1111" || r.fields[1].Value != int64(1710000000000009) {
+		t.Errorf("fields = %v", r.fields)
+	}
+}
+
+func TestRedactNumericFieldsByDetectors(t *testing.T) {
+	rd := newTestRedactor(t, MaskFull, RedactDetectors()...)
+	r := &Record{fields: []Field{
+		{Key: "phone", Value: int64(13812345678)},
+		{Key: "card", Value: uint64(4111111111111111)},
+		{Key: "phone_str", Value: "13812345678"},
+		{Key: "count", Value: 42},
+		{Key: "ts", Value: int64(1710000000000)},
+	}}
+	rd.redactRecord(r)
+
+	want := []interface{}{"***********", "****************", "***********", 42, int64(1710000000000)}
+	for i, f := range r.fields {
+		if f.Value != want[i] {
+			t.Errorf("%s = %T %v, want %T %v", f.Key, f.Value, f.Value, want[i], want[i])
+		}
+	}
+	for _, name := range []string{"bearer", "email"} {
+		if rule, _ := NewDetectorRule(name, MaskFull); rule.Numeric {
+			t.Errorf("%s is numeric", name)
+		}
+	}
+}
+
+func TestRedactorReadWhileWriterStalled(t *testing.T) {
+	l, _ := stalledLogger(t)
+	rd := NewRedactor()
+	l.SetRedactor(rd)
+
+	done := make(chan struct{})
+	go func() {
+		_ = NewAdminHandler(l).State()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("State blocked on the stalled writer")
+	}
+	if l.Redactor() != rd {
+		t.Error("Redactor is not the one set")
+	}
+}
+
+func TestNewRedactorWithConf(t *testing.T) {
+	rd, err := NewRedactorWithConf(&ConfRedact{})
+	if rd != nil || err != nil {
+		t.Fatalf("disabled = %v, %v", rd, err)
+	}
+
+	rd, err = NewRedactorWithConf(&ConfRedact{
+		Enable:    true,
+		Detectors: []string{"email"},
+		Mask:      "partial",
+		Rules:     []ConfRedactRule{{Name: "uid", Pattern: `^\d+$`, Mask: "hash", Numeric: true}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rd.rules) != 2 || rd.rules[0].Mask != MaskPartial || rd.rules[1].Mask != MaskHash || !rd.rules[1].Numeric {
+		t.Fatalf("rules = %+v", rd.rules)
+	}
+
+	for _, conf := range []ConfRedact{
+		{Enable: true, Mask: "half"},
+		{Enable: true, Detectors: []string{"passport"}},
+		{Enable: true, Rules: []ConfRedactRule{{Name: "bad", Pattern: "("}}},
+		{Enable: true, Rules: []ConfRedactRule{{Name: "bad", Pattern: "x", Mask: "half"}}},
+	} {
+		if _, err := NewRedactorWithConf(&conf); err == nil {
+			t.Errorf("%+v accepted", conf)
+		}
+	}
+	if _, err := NewDetectorRule("passport", MaskFull); err == nil || !strings.Contains(err.Error(), "passport") {
+		t.Errorf("err = %v", err)
+	}
+}
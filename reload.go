@@ -0,0 +1,125 @@
+package log4go
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const watchIntervalDefault = 5 * time.Second
+
+// ConfWatcher reload the json config file when it changes or on SIGHUP
+type ConfWatcher struct {
+	file     string
+	interval time.Duration
+	content  []byte
+	modTime  time.Time
+
+	lock    sync.Mutex // guard content, modTime and onError
+	onError func(error)
+	sig     chan os.Signal
+	stop    chan struct{}
+	once    sync.Once
+}
+
+// WatchLogConf setup log with the config file, then reload it when the file
+// changes or the process receives SIGHUP. The file is checked every interval,
+// 5s if interval <= 0. A bad file is rejected and the running config is kept.
+func WatchLogConf(file string, interval time.Duration) (*ConfWatcher, error) {
+	if interval <= 0 {
+		interval = watchIntervalDefault
+	}
+	w := &ConfWatcher{
+		file:     file,
+		interval: interval,
+		onError: func(err error) {
+			logPrintf("log4go reload %s failed, keep the running config: %v\n", file, err)
+		},
+		sig:  make(chan os.Signal, 1),
+		stop: make(chan struct{}),
+	}
+	if err := w.Reload(); err != nil {
+		return nil, err
+	}
+	signal.Notify(w.sig, syscall.SIGHUP)
+	go w.run()
+	return w, nil
+}
+
+// OnError set the callback of the reload errors, default log them
+func (w *ConfWatcher) OnError(f func(error)) {
+	w.lock.Lock()
+	w.onError = f
+	w.lock.Unlock()
+}
+
+// Reload read the config file and apply it if the content changed
+func (w *ConfWatcher) Reload() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	fi, err := os.Stat(w.file)
+	if err != nil {
+		return err
+	}
+	content, err := ioutil.ReadFile(w.file)
+	if err != nil {
+		return err
+	}
+	w.modTime = fi.ModTime()
+	if w.content != nil && bytes.Equal(content, w.content) {
+		return nil
+	}
+
+	var lc LogConfig
+	if err = json.Unmarshal(content, &lc); err != nil {
+		return err
+	}
+	if err = SetupLog(lc); err != nil {
+		return err
+	}
+	w.content = content
+	return nil
+}
+
+// Stop stop watching, the running config is kept
+func (w *ConfWatcher) Stop() {
+	w.once.Do(func() {
+		signal.Stop(w.sig)
+		close(w.stop)
+	})
+}
+
+func (w *ConfWatcher) run() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			fi, err := os.Stat(w.file)
+			w.lock.Lock()
+			unchanged := err != nil || fi.ModTime().Equal(w.modTime)
+			w.lock.Unlock()
+			if unchanged {
+				continue
+			}
+		case <-w.sig:
+		case <-w.stop:
+			return
+		}
+		if err := w.Reload(); err != nil {
+			w.lock.Lock()
+			onError := w.onError
+			w.lock.Unlock()
+			if onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package log4go
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestConf write lc as the json config file, its modification time moved
+// forward so the watcher sees the change
+func writeTestConf(t *testing.T, p string, lc LogConfig, modTime time.Time) {
+	t.Helper()
+	b, err := json.Marshal(lc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, p, string(b), modTime)
+}
+
+func newTestWatcher(t *testing.T, lc LogConfig, interval time.Duration) (*ConfWatcher, string) {
+	t.Helper()
+	p := filepath.Join(tempDir(t), "log.json")
+	writeTestConf(t, p, lc, time.Now().Add(-time.Hour))
+	w, err := WatchLogConf(p, interval)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		w.Stop()
+		_ = SetupLog(LogConfig{})
+	})
+	return w, p
+}
+
+func TestWatchLogConfRejectsBadConfig(t *testing.T) {
+	dir := tempDir(t)
+	lc := LogConfig{Level: "INFO", FileWriter: ConfFileWriter{Enable: true, PathPattern: filepath.Join(dir, "app.log")}}
+	w, p := newTestWatcher(t, lc, time.Hour)
+	file := running.writers["file"]
+
+	for _, content := range []string{`{"level":`, `{"level":"LOUD"}`, `{"tunnel_size":-1}`} {
+		writeTestFile(t, p, content, time.Now())
+		if err := w.Reload(); err == nil {
+			t.Errorf("%s accepted", content)
+		}
+		if GetLevel() != INFO || running.writers["file"] != file {
+			t.Fatalf("%s changed the running config", content)
+		}
+	}
+	if err := os.Remove(p); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reload(); err == nil {
+		t.Error("missing file accepted")
+	}
+}
+
+func TestWatchLogConfClosesRemovedWriter(t *testing.T) {
+	dir := tempDir(t)
+	lc := LogConfig{Level: "INFO", FileWriter: ConfFileWriter{Enable: true, PathPattern: filepath.Join(dir, "app.log")}}
+	w, p := newTestWatcher(t, lc, time.Hour)
+	file := running.writers["file"].(*FileWriter)
+	Info("before reload")
+	Flush()
+
+	lc.FileWriter.Enable = false
+	writeTestConf(t, p, lc, time.Now())
+	if err := w.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := running.writers["file"]; ok || loggerDefault.hasWriter(file) {
+		t.Fatal("the removed file writer is still registered")
+	}
+	if file.file != nil || file.filePath != "" {
+		t.Error("the removed file writer is not closed")
+	}
+	if content := readTestFile(t, filepath.Join(dir, "app.log")); !strings.Contains(content, "before reload") {
+		t.Errorf("file = %q", content)
+	}
+}
+
+func TestWatchLogConfAppliesLevel(t *testing.T) {
+	w, p := newTestWatcher(t, LogConfig{Level: "INFO"}, 10*time.Millisecond)
+	errs := make(chan error, 10)
+	// set while the watcher runs
+	w.OnError(func(err error) { errs <- err })
+
+	writeTestConf(t, p, LogConfig{Level: "WARN"}, time.Now())
+	deadline := time.Now().Add(2 * time.Second)
+	for GetLevel() != WARNING {
+		if time.Now().After(deadline) {
+			t.Fatalf("level = %s, the watcher did not reload", LevelFlags[GetLevel()])
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if err := ioutil.WriteFile(p, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.Chtimes(p, time.Now().Add(time.Minute), time.Now().Add(time.Minute))
+	select {
+	case <-errs:
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnError not called for the bad file")
+	}
+	if GetLevel() != WARNING {
+		t.Errorf("level = %s after the bad file", LevelFlags[GetLevel()])
+	}
+}
@@ -0,0 +1,183 @@
+package log4go
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Sampler limit the records of every call site, keyed by the code file:line.
+// In every interval the first records of a site are logged, then every
+// thereafter-th record, the others are suppressed. When the interval of a site
+// ends, a record "suppressed N similar records" is written for it.
+type Sampler struct {
+	interval time.Duration
+
+	mu    sync.Mutex
+	rules [len(LevelFlags)]samplingRule
+	sites map[string]*sampledSite
+}
+
+type samplingRule struct {
+	first      int // < 0 if the level is not sampled
+	thereafter int
+}
+
+type sampledSite struct {
+	level      int
+	node       *loggerNode // logger of the last record, the summary goes to its writers
+	start      time.Time
+	count      int
+	suppressed int
+}
+
+// NewSampler create sampler logging the first records of a call site per
+// interval, then every thereafter-th, none if thereafter is 0
+func NewSampler(interval time.Duration, first, thereafter int) *Sampler {
+	s := &Sampler{interval: interval, sites: make(map[string]*sampledSite)}
+	for i := range s.rules {
+		s.rules[i] = samplingRule{first: first, thereafter: thereafter}
+	}
+	return s
+}
+
+// SetLevel override first and thereafter for the level, a first < 0 logs every
+// record of the level
+func (s *Sampler) SetLevel(level, first, thereafter int) *Sampler {
+	if level < TRACE || level > FATAL {
+		return s
+	}
+	s.mu.Lock()
+	s.rules[level] = samplingRule{first: first, thereafter: thereafter}
+	s.mu.Unlock()
+	return s
+}
+
+// allow whether the record of the call site is logged, suppressed is the count
+// of the interval which just ended, 0 if none
+func (s *Sampler) allow(code string, level int, node *loggerNode, now time.Time) (ok bool, suppressed int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rule := s.rules[level]
+	if rule.first < 0 {
+		return true, 0
+	}
+	site := s.sites[code]
+	if site == nil {
+		site = &sampledSite{start: now}
+		s.sites[code] = site
+	} else if now.Sub(site.start) >= s.interval {
+		suppressed = site.suppressed
+		*site = sampledSite{start: now}
+	}
+	site.level = level
+	site.node = node
+	site.count++
+	n := site.count - rule.first
+	if n <= 0 || (rule.thereafter > 0 && n%rule.thereafter == 0) {
+		return true, suppressed
+	}
+	site.suppressed++
+	return false, suppressed
+}
+
+// expired remove the sites whose interval ended, return the ones which suppressed records
+func (s *Sampler) expired(now time.Time) map[string]sampledSite {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ended map[string]sampledSite
+	for code, site := range s.sites {
+		if now.Sub(site.start) < s.interval {
+			continue
+		}
+		if site.suppressed > 0 {
+			if ended == nil {
+				ended = make(map[string]sampledSite)
+			}
+			ended[code] = *site
+		}
+		delete(s.sites, code)
+	}
+	return ended
+}
+
+// WithSampler sample the records of every call site, see SetSampler
+func WithSampler(s *Sampler) LoggerOption {
+	return func(l *Logger) {
+		l.sampler = s
+	}
+}
+
+// SetSampler sample the records of every call site with s, nil logs every record
+func (l *Logger) SetSampler(s *Sampler) {
+	l.lock.Lock()
+	l.sampler = s
+	l.lock.Unlock()
+}
+
+func (l *Logger) getSampler() *Sampler {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return l.sampler
+}
+
+// sample whether the record of the call site is logged, write the summary of
+// the interval which just ended
+func (l *Logger) sample(code string, level int) bool {
+	s := l.getSampler()
+	if s == nil {
+		return true
+	}
+	ok, suppressed := s.allow(code, level, l.node, time.Now())
+	if suppressed > 0 {
+		l.sendOrDivert(l.sampledRecord(code, level, suppressed))
+	}
+	return ok
+}
+
+// reportSampled send the summary of the sites whose interval ended, after the
+// records of the sites still in the tunnel. Started by the writer goroutine,
+// which must not wait for the tunnel, a run is skipped while one is blocked.
+func (l *Logger) reportSampled() {
+	s := l.getSampler()
+	if s == nil || !atomic.CompareAndSwapInt32(&l.reporting, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&l.reporting, 0)
+
+	for code, site := range s.expired(time.Now()) {
+		r := l.sampledRecord(code, site.level, site.suppressed)
+		r.node = site.node
+		l.sendOrDivert(r)
+	}
+}
+
+func (l *Logger) sampledRecord(code string, level int, suppressed int) *Record {
+	r := l.newRecord(level, code, "suppressed "+formatCount(suppressed)+" similar records")
+	r.fields = append(r.fields, Field{Key: "suppressed", Value: suppressed})
+	return r
+}
+
+// formatCount format n with thousands separators, ex: 12,345
+func formatCount(n int) string {
+	s := strconv.Itoa(n)
+	if len(s) <= 3 {
+		return s
+	}
+	b := make([]byte, 0, len(s)+len(s)/3)
+	for i := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b = append(b, ',')
+		}
+		b = append(b, s[i])
+	}
+	return string(b)
+}
+
+// SetSampler loggerDefault sample the records of every call site
+func SetSampler(s *Sampler) {
+	loggerDefault.SetSampler(s)
+}
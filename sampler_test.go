@@ -0,0 +1,118 @@
+package log4go
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSamplerAllow(t *testing.T) {
+	s := NewSampler(time.Minute, 2, 3).SetLevel(ERROR, -1, 0)
+	now := time.Now()
+
+	var got []bool
+	for i := 0; i < 8; i++ {
+		ok, _ := s.allow("a.go:1", INFO, nil, now)
+		got = append(got, ok)
+	}
+	// first 2, then every 3rd
+	want := []bool{true, true, false, false, true, false, false, true}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("allowed = %v, want %v", got, want)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		if ok, _ := s.allow("a.go:1", ERROR, nil, now); !ok {
+			t.Fatal("ERROR sampled, its first is < 0")
+		}
+	}
+	if ok, _ := s.allow("b.go:2", INFO, nil, now); !ok {
+		t.Error("another site sampled with a.go:1")
+	}
+
+	ok, suppressed := s.allow("a.go:1", INFO, nil, now.Add(time.Minute))
+	if !ok || suppressed != 4 {
+		t.Errorf("next interval = %v, %d suppressed, want true, 4", ok, suppressed)
+	}
+}
+
+func TestSamplerSummaryInCall(t *testing.T) {
+	l, w := newTestLogger(t, WithSampler(NewSampler(50*time.Millisecond, 1, 0)))
+	// a single call site
+	info := func(msg string) { l.Info(msg) }
+	for i := 0; i < 4; i++ {
+		info("tick")
+	}
+	time.Sleep(60 * time.Millisecond)
+	for i := 0; i < 4; i++ {
+		info("tock")
+	}
+	l.Flush()
+
+	want := "tick,suppressed 3 similar records,tock"
+	if got := strings.Join(w.messages(), ","); got != want {
+		t.Fatalf("messages = %s, want %s", got, want)
+	}
+}
+
+func TestReportSampledAfterQueuedRecords(t *testing.T) {
+	w := &blockingWriter{release: make(chan struct{})}
+	l := NewLogger(WithSampler(NewSampler(50*time.Millisecond, 3, 0)))
+	l.Register(w)
+	defer l.Close()
+
+	for i := 0; i < 6; i++ {
+		l.Info("tick")
+	}
+	time.Sleep(60 * time.Millisecond)
+	// the writer holds the first record, the two others are in the tunnel
+	l.reportSampled()
+	close(w.release)
+	l.Flush()
+
+	want := "tick,tick,tick,suppressed 3 similar records"
+	if got := strings.Join(w.messages(), ","); got != want {
+		t.Fatalf("messages = %s, want %s", got, want)
+	}
+	if v, _ := w.records[3].Fields()[0].Value.(int); v != 3 {
+		t.Errorf("suppressed field = %v", w.records[3].Fields())
+	}
+}
+
+func TestReportSampledSkippedWhileRunning(t *testing.T) {
+	s := NewSampler(time.Millisecond, 1, 0)
+	l, w := newTestLogger(t, WithSampler(s))
+	for i := 0; i < 2; i++ {
+		l.Info("tick")
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	l.reporting = 1
+	l.reportSampled()
+	l.reporting = 0
+	if len(s.sites) != 1 {
+		t.Fatal("the skipped run removed the site")
+	}
+	l.reportSampled()
+	l.Flush()
+	if got := strings.Join(w.messages(), ","); got != "tick,suppressed 1 similar records" {
+		t.Fatalf("messages = %s", got)
+	}
+}
+
+func TestFormatCount(t *testing.T) {
+	for n, want := range map[int]string{
+		0:        "0",
+		999:      "999",
+		1000:     "1,000",
+		12345:    "12,345",
+		999999:   "999,999",
+		1234567:  "1,234,567",
+		10000000: "10,000,000",
+	} {
+		if got := formatCount(n); got != want {
+			t.Errorf("formatCount(%d) = %s, want %s", n, got, want)
+		}
+	}
+}
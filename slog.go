@@ -0,0 +1,212 @@
+//go:build go1.21
+// +build go1.21
+
+package log4go
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// SlogHandler slog.Handler writing the slog records to a log4go Logger, so
+// slog and log4go land in the same writers. The attrs become fields, the
+// keys in a group are prefixed with the group name, ex: "req.method".
+type SlogHandler struct {
+	l      *Logger
+	fields []Field // attrs of WithAttrs, keys already prefixed
+	prefix string  // groups of WithGroup, ex: "req."
+}
+
+// NewSlogHandler create slog handler writing to l, loggerDefault if l is nil
+func NewSlogHandler(l *Logger) *SlogHandler {
+	if l == nil {
+		l = loggerDefault
+	}
+	return &SlogHandler{l: l}
+}
+
+// Enabled whether the logger writes the level
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.l.Enabled(levelFromSlog(level))
+}
+
+// Handle convert the slog record into a log4go record and send it to the writers
+func (h *SlogHandler) Handle(ctx context.Context, sr slog.Record) error {
+	l := h.l
+	level := levelFromSlog(sr.Level)
+	now := sr.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	var code string
+	if sr.PC != 0 {
+		f, _ := runtime.CallersFrames([]uintptr{sr.PC}).Next()
+		if f.File != "" {
+			code = l.code(f.File, f.Line)
+		}
+	}
+
+	r := l.newRecordAt(now, level, code, sr.Message)
+	r.pc = sr.PC
+	r.fields = append(r.fields, l.fields...)
+	r.fields = append(r.fields, h.fields...)
+	sr.Attrs(func(a slog.Attr) bool {
+		r.fields = appendAttr(r.fields, h.prefix, a)
+		return true
+	})
+	r.fields = l.extractContext(ctx, r.fields)
+	if int32(level) >= atomic.LoadInt32(&l.stackLevel) {
+		r.stack = l.captureStackAt(r.stack, sr.PC)
+	}
+
+	l.sendOrDivert(r)
+	return nil
+}
+
+// WithAttrs handler adding the attrs to every record
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	c := *h
+	c.fields = make([]Field, len(h.fields), len(h.fields)+len(attrs))
+	copy(c.fields, h.fields)
+	for _, a := range attrs {
+		c.fields = appendAttr(c.fields, h.prefix, a)
+	}
+	return &c
+}
+
+// WithGroup handler prefixing the keys of the following attrs with name
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	c := *h
+	c.prefix = h.prefix + name + "."
+	return &c
+}
+
+// appendAttr append the attr as fields, a group is flattened with its name as prefix
+func appendAttr(fields []Field, prefix string, a slog.Attr) []Field {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		attrs := a.Value.Group()
+		if a.Key != "" {
+			prefix = prefix + a.Key + "."
+		}
+		for _, ga := range attrs {
+			fields = appendAttr(fields, prefix, ga)
+		}
+		return fields
+	}
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+	return append(fields, Field{Key: prefix + a.Key, Value: a.Value.Any()})
+}
+
+// levelFromSlog the log4go level of a slog level, never above ERROR
+func levelFromSlog(level slog.Level) int {
+	switch {
+	case level < slog.LevelDebug:
+		return TRACE
+	case level < slog.LevelInfo:
+		return DEBUG
+	case level < slog.LevelWarn:
+		return INFO
+	case level < slog.LevelError:
+		return WARNING
+	}
+	return ERROR
+}
+
+// levelToSlog the slog level of a log4go level
+func levelToSlog(level int) slog.Level {
+	switch level {
+	case TRACE:
+		return slog.LevelDebug - 4
+	case DEBUG:
+		return slog.LevelDebug
+	case INFO:
+		return slog.LevelInfo
+	case WARNING:
+		return slog.LevelWarn
+	case ERROR:
+		return slog.LevelError
+	case PANIC:
+		return slog.LevelError + 4
+	}
+	return slog.LevelError + 8
+}
+
+// SlogWriter writer forwarding the records to a slog.Handler, the fields
+// become attrs and the stack, if any, a "stack" attr
+type SlogWriter struct {
+	h     slog.Handler
+	level int32 // accessed atomically
+}
+
+// NewSlogWriter create writer forwarding to h
+func NewSlogWriter(h slog.Handler) *SlogWriter {
+	return NewSlogWriterWithLevel(DEBUG, h)
+}
+
+// NewSlogWriterWithLevel create writer forwarding to h with level
+func NewSlogWriterWithLevel(level int, h slog.Handler) *SlogWriter {
+	defaultLevel := DEBUG
+	maxLevel := len(LevelFlags)
+	// maxLevel >= 1 always true
+	maxLevel = maxLevel - 1
+
+	if level >= TRACE && level <= maxLevel {
+		defaultLevel = level
+	}
+	return &SlogWriter{h: h, level: int32(defaultLevel)}
+}
+
+// Init slog writer init
+func (w *SlogWriter) Init() error {
+	return nil
+}
+
+// Write slog writer write
+func (w *SlogWriter) Write(r *Record) error {
+	if int32(r.level) < atomic.LoadInt32(&w.level) {
+		return nil
+	}
+	ctx := context.Background()
+	level := levelToSlog(r.level)
+	if !w.h.Enabled(ctx, level) {
+		return nil
+	}
+	sr := slog.NewRecord(r.ts, level, r.info, r.pc)
+	for _, f := range r.fields {
+		sr.AddAttrs(slog.Any(f.Key, f.Value))
+	}
+	if r.node != nil {
+		sr.AddAttrs(slog.String("logger", r.node.name))
+	}
+	if len(r.stack) > 0 {
+		stack := make([]string, len(r.stack))
+		for i, f := range r.stack {
+			stack[i] = f.String()
+		}
+		sr.AddAttrs(slog.Any("stack", stack))
+	}
+	return w.h.Handle(ctx, sr)
+}
+
+// Level slog writer level
+func (w *SlogWriter) Level() int {
+	return int(atomic.LoadInt32(&w.level))
+}
+
+// SetLevel slog writer set level
+func (w *SlogWriter) SetLevel(level int) {
+	atomic.StoreInt32(&w.level, int32(level))
+}
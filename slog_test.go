@@ -0,0 +1,147 @@
+//go:build go1.21
+// +build go1.21
+
+package log4go
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlogLevels(t *testing.T) {
+	tests := []struct {
+		slog   slog.Level
+		log4go int
+	}{
+		{slog.LevelDebug - 8, TRACE},
+		{slog.LevelDebug - 4, TRACE},
+		{slog.LevelDebug, DEBUG},
+		{slog.LevelDebug + 1, DEBUG},
+		{slog.LevelInfo, INFO},
+		{slog.LevelWarn, WARNING},
+		{slog.LevelError, ERROR},
+		{slog.LevelError + 8, ERROR},
+	}
+	for _, tt := range tests {
+		if got := levelFromSlog(tt.slog); got != tt.log4go {
+			t.Errorf("levelFromSlog(%s) = %s, want %s", tt.slog, LevelFlags[got], LevelFlags[tt.log4go])
+		}
+	}
+	for level := TRACE; level <= FATAL; level++ {
+		want := level
+		if want > ERROR {
+			want = ERROR
+		}
+		if back := levelFromSlog(levelToSlog(level)); back != want {
+			t.Errorf("levelFromSlog(levelToSlog(%s)) = %s, want %s", LevelFlags[level], LevelFlags[back], LevelFlags[want])
+		}
+	}
+}
+
+type slogValuer struct{}
+
+func (slogValuer) LogValue() slog.Value {
+	return slog.StringValue("resolved")
+}
+
+func TestSlogHandler(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(sl *slog.Logger)
+		want string // fields
+	}{
+		{"attrs", func(sl *slog.Logger) { sl.Info("msg", "uid", 7, slog.Bool("ok", true)) }, " uid=7 ok=true"},
+		{"with attrs", func(sl *slog.Logger) { sl.With("service", "api").Info("msg", "uid", 7) }, " service=api uid=7"},
+		{"with group", func(sl *slog.Logger) { sl.WithGroup("req").Info("msg", "method", "GET") }, " req.method=GET"},
+		{"group attr", func(sl *slog.Logger) {
+			sl.Info("msg", slog.Group("req", "method", "GET", slog.Group("hdr", "ua", "curl")))
+		}, " req.method=GET req.hdr.ua=curl"},
+		{"with attrs before group", func(sl *slog.Logger) {
+			sl.With("service", "api").WithGroup("req").With("id", 1).Info("msg", "method", "GET")
+		}, " service=api req.id=1 req.method=GET"},
+		{"inline group", func(sl *slog.Logger) { sl.Info("msg", slog.Group("", "a", 1)) }, " a=1"},
+		{"empty attr", func(sl *slog.Logger) { sl.Info("msg", slog.Attr{}, "uid", 7) }, " uid=7"},
+		{"empty group name", func(sl *slog.Logger) { sl.WithGroup("").Info("msg", "uid", 7) }, " uid=7"},
+		{"valuer", func(sl *slog.Logger) { sl.Info("msg", "v", slogValuer{}) }, " v=resolved"},
+		{"context", func(sl *slog.Logger) {
+			sl.InfoContext(ContextWithTrace(context.Background(), "t1", "s1"), "msg")
+		}, " trace_id=t1 span_id=s1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, w := newTestLogger(t)
+			tt.log(slog.New(NewSlogHandler(l)))
+			l.Flush()
+			r := w.last()
+			if r.info != "msg" || r.level != INFO {
+				t.Fatalf("record %s %s", LevelFlags[r.level], r.info)
+			}
+			if got := fieldsString(r.fields); got != tt.want {
+				t.Fatalf("fields = %q, want %q", got, tt.want)
+			}
+			if !strings.HasPrefix(r.code, "slog_test.go:") {
+				t.Fatalf("code = %s", r.code)
+			}
+		})
+	}
+}
+
+func TestSlogHandlerEnabled(t *testing.T) {
+	l, w := newTestLogger(t)
+	l.SetLevel(WARNING)
+	sl := slog.New(NewSlogHandler(l))
+	sl.Info("info")
+	sl.Warn("warn")
+	sl.Error("error")
+	l.Flush()
+	if got := strings.Join(w.messages(), ","); got != "warn,error" {
+		t.Fatalf("messages = %s", got)
+	}
+}
+
+func TestSlogWriter(t *testing.T) {
+	var buf bytes.Buffer
+	h := slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug - 4,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	ts := time.Now()
+	tests := []struct {
+		name   string
+		writer *SlogWriter
+		r      *Record
+		want   string
+	}{
+		{"info", NewSlogWriter(h), &Record{ts: ts, level: INFO, info: "hi"}, "level=INFO msg=hi\n"},
+		{"fields", NewSlogWriter(h), &Record{ts: ts, level: WARNING, info: "hi", fields: []Field{F("uid", 7)}},
+			"level=WARN msg=hi uid=7\n"},
+		{"logger", NewSlogWriter(h), &Record{ts: ts, level: ERROR, info: "hi", node: &loggerNode{name: "db"}},
+			"level=ERROR msg=hi logger=db\n"},
+		{"stack", NewSlogWriter(h), &Record{ts: ts, level: ERROR, info: "hi",
+			stack: []StackFrame{{Function: "main.main", File: "main.go", Line: 9}}},
+			"level=ERROR msg=hi stack=\"[main.main main.go:9]\"\n"},
+		{"trace", NewSlogWriterWithLevel(TRACE, h), &Record{ts: ts, level: TRACE, info: "hi"}, "level=DEBUG-4 msg=hi\n"},
+		{"below writer level", NewSlogWriter(h), &Record{ts: ts, level: TRACE, info: "hi"}, ""},
+		{"fatal", NewSlogWriter(h), &Record{ts: ts, level: FATAL, info: "hi"}, "level=ERROR+8 msg=hi\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			if err := tt.writer.Write(tt.r); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Fatalf("output = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package log4go
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	stackDepthDefault = 32
+	stackOff          = len(LevelFlags) // no level captures the stack
+)
+
+// stackSkipDefault packages left out of the stack trace by default
+var stackSkipDefault = []string{"runtime"}
+
+// StackFrame one frame of the stack trace of a record
+type StackFrame struct {
+	Function string
+	File     string
+	Line     int
+}
+
+// String "function file:line"
+func (f StackFrame) String() string {
+	return f.Function + " " + f.File + ":" + strconv.Itoa(f.Line)
+}
+
+// WithStackTrace capture the stack of the records at level or above, see SetStackTrace
+func WithStackTrace(level, depth int, skip ...string) LoggerOption {
+	return func(l *Logger) {
+		l.setStackTrace(level, depth, skip)
+	}
+}
+
+// SetStackTrace capture the goroutine stack of the records at level or above,
+// up to depth frames (default 32). The frames of the skip packages, ex:
+// "runtime" or "github.com/foo/bar", are left out, default "runtime".
+// A level out of TRACE..FATAL disables the capture.
+func (l *Logger) SetStackTrace(level, depth int, skip ...string) {
+	l.setStackTrace(level, depth, skip)
+}
+
+func (l *Logger) setStackTrace(level, depth int, skip []string) {
+	if level < TRACE || level > FATAL {
+		level = stackOff
+	}
+	if depth <= 0 {
+		depth = stackDepthDefault
+	}
+	if skip == nil {
+		skip = stackSkipDefault
+	}
+	l.lock.Lock()
+	l.stackDepth = depth
+	l.stackSkip = append([]string(nil), skip...)
+	l.lock.Unlock()
+	atomic.StoreInt32(&l.stackLevel, int32(level))
+}
+
+// captureStack append the stack of the caller to frames, skip like runtime.Caller
+func (l *Logger) captureStack(frames []StackFrame, skip int) []StackFrame {
+	l.lock.RLock()
+	depth := l.stackDepth
+	l.lock.RUnlock()
+
+	pcs := make([]uintptr, depth+16)
+	// +2: runtime.Callers and captureStack
+	n := runtime.Callers(skip+2, pcs)
+	return l.stackFrames(frames, pcs[:n])
+}
+
+// captureStackAt append the stack of the current goroutine from the frame of
+// pc on, as recorded by the caller, the whole stack if pc is not found
+func (l *Logger) captureStackAt(frames []StackFrame, pc uintptr) []StackFrame {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(2, pcs)
+	pcs = pcs[:n]
+	for i := range pcs {
+		if pcs[i] == pc {
+			return l.stackFrames(frames, pcs[i:])
+		}
+	}
+	return l.stackFrames(frames, pcs)
+}
+
+// stackFrames append the frames of the program counters, as returned by runtime.Callers
+func (l *Logger) stackFrames(frames []StackFrame, pcs []uintptr) []StackFrame {
+	l.lock.RLock()
+	depth, skipPkgs := l.stackDepth, l.stackSkip
+	l.lock.RUnlock()
+
+	it := runtime.CallersFrames(pcs)
+	for len(frames) < depth {
+		f, more := it.Next()
+		if !skipFrame(f.Function, skipPkgs) {
+			frames = append(frames, StackFrame{Function: f.Function, File: f.File, Line: f.Line})
+		}
+		if !more {
+			break
+		}
+	}
+	return frames
+}
+
+// skipFrame whether the function belongs to one of the packages
+func skipFrame(function string, pkgs []string) bool {
+	pkg := funcPackage(function)
+	for _, p := range pkgs {
+		if pkg == p || strings.HasPrefix(pkg, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// funcPackage package path of a function name, ex: "github.com/a/b.(*T).M" -> "github.com/a/b"
+func funcPackage(function string) string {
+	slash := strings.LastIndexByte(function, '/')
+	if dot := strings.IndexByte(function[slash+1:], '.'); dot >= 0 {
+		return function[:slash+1+dot]
+	}
+	return function
+}
+
+// writeStack write the frames as indented lines, like a go panic
+func writeStack(buf *bytes.Buffer, frames []StackFrame) {
+	if len(frames) == 0 {
+		return
+	}
+	if buf.Len() > 0 && buf.Bytes()[buf.Len()-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+	for _, f := range frames {
+		buf.WriteByte('\t')
+		buf.WriteString(f.Function)
+		buf.WriteString("\n\t\t")
+		buf.WriteString(f.File)
+		buf.WriteByte(':')
+		buf.WriteString(strconv.Itoa(f.Line))
+		buf.WriteByte('\n')
+	}
+}
+
+// stackString the frames as written by writeStack
+func stackString(frames []StackFrame) string {
+	if len(frames) == 0 {
+		return ""
+	}
+	var buf bytes.Buffer
+	writeStack(&buf, frames)
+	return buf.String()
+}
+
+// SetStackTrace loggerDefault capture the stack of the records at level or above
+func SetStackTrace(level, depth int, skip ...string) {
+	loggerDefault.SetStackTrace(level, depth, skip...)
+}
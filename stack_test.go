@@ -0,0 +1,99 @@
+package log4go
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFuncPackage(t *testing.T) {
+	tests := []struct {
+		function, want string
+	}{
+		{"main.main", "main"},
+		{"runtime.goexit", "runtime"},
+		{"github.com/kdpujie/log4go.(*Logger).Error", "github.com/kdpujie/log4go"},
+		{"github.com/kdpujie/log4go.TestStack.func1", "github.com/kdpujie/log4go"},
+		{"gopkg.in/yaml.v2.Unmarshal", "gopkg.in/yaml"},
+		{"nodot", "nodot"},
+	}
+	for _, tt := range tests {
+		if got := funcPackage(tt.function); got != tt.want {
+			t.Errorf("funcPackage(%s) = %s, want %s", tt.function, got, tt.want)
+		}
+	}
+}
+
+func TestSkipFrame(t *testing.T) {
+	tests := []struct {
+		function string
+		pkgs     []string
+		want     bool
+	}{
+		{"runtime.goexit", []string{"runtime"}, true},
+		{"runtime/debug.Stack", []string{"runtime"}, true},
+		{"runtimex.F", []string{"runtime"}, false},
+		{"github.com/foo/bar.F", []string{"github.com/foo"}, true},
+		{"github.com/foo/barbaz.F", []string{"github.com/foo/bar"}, false},
+		{"main.main", nil, false},
+	}
+	for _, tt := range tests {
+		if got := skipFrame(tt.function, tt.pkgs); got != tt.want {
+			t.Errorf("skipFrame(%s, %v) = %v, want %v", tt.function, tt.pkgs, got, tt.want)
+		}
+	}
+}
+
+func TestStackTraceCapture(t *testing.T) {
+	tests := []struct {
+		name  string
+		level int
+		depth int
+		skip  []string
+		// whether the records of Info, Error and Errorw have a stack
+		want [3]bool
+	}{
+		{"error and above", ERROR, 0, nil, [3]bool{false, true, true}},
+		{"info and above", INFO, 0, nil, [3]bool{true, true, true}},
+		{"off", FATAL + 1, 0, nil, [3]bool{false, false, false}},
+		{"depth", ERROR, 1, nil, [3]bool{false, true, true}},
+		{"skip testing", ERROR, 0, []string{"runtime", "testing"}, [3]bool{false, true, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, w := newTestLogger(t, WithStackTrace(tt.level, tt.depth, tt.skip...))
+			l.Info("info")
+			l.Error("error")
+			l.Errorw("errorw", "uid", 7)
+			l.Flush()
+
+			l.lock.RLock()
+			depth := l.stackDepth
+			l.lock.RUnlock()
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			for i, r := range w.records {
+				has := len(r.stack) > 0
+				if has != tt.want[i] {
+					t.Fatalf("%s: has stack %v, want %v", r.info, has, tt.want[i])
+				}
+				if !has {
+					continue
+				}
+				if len(r.stack) > depth {
+					t.Fatalf("%s: %d frames, depth %d", r.info, len(r.stack), depth)
+				}
+				// the stack starts at the log call
+				if f := r.stack[0]; !strings.HasSuffix(f.Function, ".TestStackTraceCapture.func1") ||
+					!strings.HasSuffix(f.File, "stack_test.go") {
+					t.Fatalf("%s: first frame %s", r.info, f)
+				}
+				for _, f := range r.stack {
+					pkg := funcPackage(f.Function)
+					if pkg == "runtime" || (pkg == "testing" && len(tt.skip) > 0) {
+						t.Fatalf("%s: frame of %s not skipped", r.info, pkg)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package log4go
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Shopify/sarama"
+)
+
+var (
+	stdDateRegexp = regexp.MustCompile(`^\d{4}/\d{2}/\d{2} `)
+	stdTimeRegexp = regexp.MustCompile(`^\d{2}:\d{2}:\d{2}(\.\d+)? `)
+	stdFileRegexp = regexp.MustCompile(`^(\S+\.go):(\d+): `)
+)
+
+// LineWriter io.Writer turning every line into a record of its level. The
+// header of the log package, prefix, date, time and Lshortfile or Llongfile,
+// is parsed: the date and time are dropped, the file becomes the code.
+//
+// The records are sent like the other log calls, written at once in sync mode.
+// While the log package is redirected, the logger reports its own errors to
+// stderr, see logPrintln.
+type LineWriter struct {
+	l      *Logger
+	level  int
+	prefix string
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+// NewLineWriter create line writer to l with level, loggerDefault if l is nil
+func NewLineWriter(l *Logger, level int) *LineWriter {
+	if l == nil {
+		l = loggerDefault
+	}
+	return &LineWriter{l: l, level: level}
+}
+
+// SetPrefix set the prefix of the log package logger, removed from the lines
+func (w *LineWriter) SetPrefix(prefix string) {
+	w.mu.Lock()
+	w.prefix = prefix
+	w.mu.Unlock()
+}
+
+// Write write the complete lines, the last partial line is kept for the next write
+func (w *LineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.writeLine(string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+	if len(w.buf) == 0 {
+		w.buf = nil
+	}
+	return len(p), nil
+}
+
+// Flush write the partial line, if any
+func (w *LineWriter) Flush() error {
+	w.mu.Lock()
+	if len(w.buf) > 0 {
+		w.writeLine(string(w.buf))
+		w.buf = nil
+	}
+	w.mu.Unlock()
+	return nil
+}
+
+func (w *LineWriter) writeLine(line string) {
+	if !w.l.Enabled(w.level) {
+		return
+	}
+	code, msg := w.parse(strings.TrimSuffix(line, "\r"))
+	w.l.outputStd(w.level, code, msg)
+}
+
+// parse split the header of the log package from the message
+func (w *LineWriter) parse(line string) (code, msg string) {
+	if w.prefix != "" {
+		line = strings.TrimPrefix(line, w.prefix)
+	}
+	if loc := stdDateRegexp.FindStringIndex(line); loc != nil {
+		line = line[loc[1]:]
+	}
+	if loc := stdTimeRegexp.FindStringIndex(line); loc != nil {
+		line = line[loc[1]:]
+	}
+	if m := stdFileRegexp.FindStringSubmatchIndex(line); m != nil {
+		n, _ := strconv.Atoi(line[m[4]:m[5]])
+		code = w.l.code(line[m[2]:m[3]], n)
+		line = line[m[1]:]
+	}
+	// Lmsgprefix
+	if w.prefix != "" {
+		line = strings.TrimPrefix(line, w.prefix)
+	}
+	return code, line
+}
+
+// outputStd send a record of the log package
+func (l *Logger) outputStd(level int, code, msg string) {
+	r := l.newRecord(level, code, msg)
+	r.std = true
+	r.fields = append(r.fields, l.fields...)
+	l.sendOrDivert(r)
+}
+
+// stdRedirected number of the RedirectStdLog not restored, accessed atomically
+var stdRedirected int32
+
+// logPrintln log the error of the logger itself with the log package, to
+// stderr while the log package is redirected: the writer goroutine, or the
+// caller holding the write lock in sync mode, would wait for itself
+func logPrintln(v ...interface{}) {
+	if atomic.LoadInt32(&stdRedirected) > 0 {
+		_, _ = fmt.Fprintln(os.Stderr, v...)
+		return
+	}
+	log.Println(v...)
+}
+
+// logPrintf like logPrintln with a format
+func logPrintf(format string, v ...interface{}) {
+	if atomic.LoadInt32(&stdRedirected) > 0 {
+		_, _ = fmt.Fprintf(os.Stderr, format, v...)
+		return
+	}
+	log.Printf(format, v...)
+}
+
+// RedirectStdLog send the output of the log package to l at level, loggerDefault
+// if l is nil. The returned func restores the previous output.
+func RedirectStdLog(l *Logger, level int) func() {
+	w := NewLineWriter(l, level)
+	w.SetPrefix(log.Prefix())
+	prev := log.Writer()
+	atomic.AddInt32(&stdRedirected, 1)
+	log.SetOutput(w)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			log.SetOutput(prev)
+			_ = w.Flush()
+			atomic.AddInt32(&stdRedirected, -1)
+		})
+	}
+}
+
+// SaramaLogger sarama.Logger writing to a log4go logger, the code is the sarama source
+type SaramaLogger struct {
+	l     *Logger
+	level int
+}
+
+// NewSaramaLogger create sarama logger to l with level, loggerDefault if l is nil
+func NewSaramaLogger(l *Logger, level int) *SaramaLogger {
+	if l == nil {
+		l = loggerDefault
+	}
+	return &SaramaLogger{l: l, level: level}
+}
+
+// Print sarama logger print
+func (s *SaramaLogger) Print(v ...interface{}) {
+	s.output(fmt.Sprint(v...))
+}
+
+// Printf sarama logger printf
+func (s *SaramaLogger) Printf(format string, v ...interface{}) {
+	s.output(fmt.Sprintf(format, v...))
+}
+
+// Println sarama logger println
+func (s *SaramaLogger) Println(v ...interface{}) {
+	s.output(fmt.Sprintln(v...))
+}
+
+func (s *SaramaLogger) output(msg string) {
+	if !s.l.Enabled(s.level) {
+		return
+	}
+	_, code := s.l.caller(2)
+	s.l.outputStd(s.level, code, strings.TrimSuffix(msg, "\n"))
+}
+
+// RedirectSaramaLog set sarama.Logger to a SaramaLogger of l at level
+func RedirectSaramaLog(l *Logger, level int) {
+	sarama.Logger = NewSaramaLogger(l, level)
+}
@@ -0,0 +1,59 @@
+package log4go
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+// errWriter writer failing every Write
+type errWriter struct{}
+
+func (errWriter) Init() error {
+	return nil
+}
+
+func (errWriter) Write(*Record) error {
+	return errors.New("write failed")
+}
+
+func TestRedirectStdLogSyncMode(t *testing.T) {
+	l, w := newTestLogger(t, WithSyncMode(FATAL+1))
+	// the error of errWriter is logged with the log package, while it is redirected
+	l.Register(errWriter{})
+	restore := RedirectStdLog(l, INFO)
+	defer restore()
+
+	done := make(chan struct{})
+	go func() {
+		log.Print("first")
+		NewSaramaLogger(l, WARNING).Printf("second %d", 2)
+		log.Print("third")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("logging with the log package blocked")
+	}
+
+	// written in the log calls, no Flush
+	if got := strings.Join(w.messages(), ","); got != "first,second 2,third" {
+		t.Fatalf("messages = %s", got)
+	}
+}
+
+func TestRedirectStdLogRestore(t *testing.T) {
+	l, _ := newTestLogger(t)
+	restore := RedirectStdLog(l, INFO)
+	if n := stdRedirected; n != 1 {
+		t.Fatalf("stdRedirected = %d, want 1", n)
+	}
+	restore()
+	restore()
+	if n := stdRedirected; n != 0 {
+		t.Fatalf("stdRedirected = %d after restore, want 0", n)
+	}
+}
@@ -0,0 +1,60 @@
+package log4go
+
+import "sync/atomic"
+
+// WithSyncMode write the records in the log calls, see SetSyncMode
+func WithSyncMode(flushLevel int) LoggerOption {
+	return func(l *Logger) {
+		l.sync = 1
+		l.flushLevel = int32(syncFlushLevel(flushLevel))
+	}
+}
+
+// SetSyncMode switch the logger to sync mode: the log calls write the records
+// to the writers themselves, under a lock, and flush the writers after a record
+// at flushLevel or above, a level out of TRACE..FATAL never flushes per record.
+// The records already in the tunnel are written first, the log calls wait
+// meanwhile. Flush, and the timer every second, still flush the writers.
+// Sync mode is off if sync is false, the records go through the tunnel again.
+func (l *Logger) SetSyncMode(sync bool, flushLevel int) {
+	atomic.StoreInt32(&l.flushLevel, int32(syncFlushLevel(flushLevel)))
+	if !sync {
+		atomic.StoreInt32(&l.sync, 0)
+		return
+	}
+	l.modeLock.Lock()
+	defer l.modeLock.Unlock()
+	if atomic.LoadInt32(&l.sync) == 0 {
+		l.Flush()
+		atomic.StoreInt32(&l.sync, 1)
+	}
+}
+
+// SyncMode whether the logger is in sync mode
+func (l *Logger) SyncMode() bool {
+	return atomic.LoadInt32(&l.sync) == 1
+}
+
+func syncFlushLevel(level int) int {
+	if level < TRACE || level > FATAL {
+		return stackOff
+	}
+	return level
+}
+
+// writeSync write the record in the log call, the closeLock is held for reading
+func (l *Logger) writeSync(r *Record) {
+	l.syncOnce.Do(func() {
+		close(l.syncStart)
+	})
+	l.writeToWriters(r)
+	if int32(r.level) >= atomic.LoadInt32(&l.flushLevel) {
+		l.syncWriters()
+	}
+	recordPool.Put(r)
+}
+
+// SetSyncMode loggerDefault switch the sync mode
+func SetSyncMode(sync bool, flushLevel int) {
+	loggerDefault.SetSyncMode(sync, flushLevel)
+}
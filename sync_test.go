@@ -0,0 +1,63 @@
+package log4go
+
+import (
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSyncModeWritesInLogCall(t *testing.T) {
+	l, w := newTestLogger(t, WithSyncMode(FATAL+1))
+	if !l.SyncMode() {
+		t.Fatal("sync mode is off")
+	}
+	for i, msg := range []string{"a", "b", "c"} {
+		l.Info(msg)
+		if msgs := w.messages(); len(msgs) != i+1 || msgs[i] != msg {
+			t.Fatalf("after Info(%q) messages = %v", msg, msgs)
+		}
+	}
+
+	l.SetSyncMode(false, FATAL+1)
+	l.Info("async")
+	l.Flush()
+	if msgs := w.messages(); len(msgs) != 4 || msgs[3] != "async" {
+		t.Fatalf("messages = %v", msgs)
+	}
+}
+
+func TestSetSyncModeKeepsOrder(t *testing.T) {
+	w := &blockingWriter{release: make(chan struct{})}
+	l := NewLogger(WithTunnelSize(tunnelSizeDefault))
+	l.Register(w)
+	t.Cleanup(l.Close)
+
+	// "a" is held by the writer, "b" waits in the tunnel
+	l.Info("a")
+	for atomic.LoadInt32(&w.writing) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	l.Info("b")
+
+	switched := make(chan struct{})
+	go func() {
+		l.SetSyncMode(true, FATAL+1)
+		close(switched)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	logged := make(chan struct{})
+	go func() {
+		l.Info("c")
+		close(logged)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(w.release)
+	<-switched
+	<-logged
+
+	l.Info("d")
+	if msgs, want := w.messages(), []string{"a", "b", "c", "d"}; !reflect.DeepEqual(msgs, want) {
+		t.Fatalf("messages = %v, want %v", msgs, want)
+	}
+}
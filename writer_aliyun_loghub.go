@@ -7,6 +7,7 @@
 package log4go
 
 import (
+	"sync/atomic"
 	"time"
 
 	sls "github.com/aliyun/aliyun-log-go-sdk"
@@ -17,7 +18,7 @@ var DefaultBufSize = 10
 
 // AliLogHubWriter ali log hub writer
 type AliLogHubWriter struct {
-	level   int
+	level   int32 // accessed atomically
 	config  *ConfAliLogHubWriter
 	project *sls.LogProject
 	store   *sls.LogStore
@@ -32,7 +33,7 @@ func NewAliLogHubWriter(conf *ConfAliLogHubWriter) *AliLogHubWriter {
 		conf.BufSize = DefaultBufSize
 	}
 	return &AliLogHubWriter{
-		level:   getLevel(conf.Level),
+		level:   int32(getLevel(conf.Level)),
 		config:  conf,
 		bufLogs: make([]*sls.Log, conf.BufSize),
 	}
@@ -44,13 +45,12 @@ func NewAliLogHubWriterWithLevel(level int, conf *ConfAliLogHubWriter) *AliLogHu
 	// maxLevel >= 1 always true
 	maxLevel = maxLevel - 1
 
-	if level >= defaultLevel && level <= maxLevel {
+	if level >= TRACE && level <= maxLevel {
 		defaultLevel = level
 	}
-	return &AliLogHubWriter{
-		level:  defaultLevel,
-		config: conf,
-	}
+	w := NewAliLogHubWriter(conf)
+	w.level = int32(defaultLevel)
+	return w
 }
 
 // Init init ali log hub writer init
@@ -65,7 +65,7 @@ func (w *AliLogHubWriter) Init() (err error) {
 
 // Write ali log hub writer write
 func (w *AliLogHubWriter) Write(r *Record) (err error) {
-	if r.level < w.level {
+	if int32(r.level) < atomic.LoadInt32(&w.level) {
 		return
 	}
 	var content []*sls.LogContent
@@ -85,6 +85,24 @@ func (w *AliLogHubWriter) Write(r *Record) (err error) {
 		Key:   proto.String("info"),
 		Value: proto.String(r.info),
 	})
+	if r.node != nil {
+		content = append(content, &sls.LogContent{
+			Key:   proto.String("logger"),
+			Value: proto.String(r.node.name),
+		})
+	}
+	for _, f := range r.fields {
+		content = append(content, &sls.LogContent{
+			Key:   proto.String(f.Key),
+			Value: proto.String(formatFieldValue(f.Value)),
+		})
+	}
+	if len(r.stack) > 0 {
+		content = append(content, &sls.LogContent{
+			Key:   proto.String("stack"),
+			Value: proto.String(stackString(r.stack)),
+		})
+	}
 	log := &sls.Log{
 		Time:     proto.Uint32(uint32(time.Now().Unix())),
 		Contents: content,
@@ -127,3 +145,13 @@ func (w *AliLogHubWriter) writeBuf(log *sls.Log) error {
 func (w *AliLogHubWriter) available() int {
 	return len(w.bufLogs) - w.n
 }
+
+// Level ali log hub writer get the level
+func (w *AliLogHubWriter) Level() int {
+	return int(atomic.LoadInt32(&w.level))
+}
+
+// SetLevel ali log hub writer set the level, safe to call while writing
+func (w *AliLogHubWriter) SetLevel(level int) {
+	atomic.StoreInt32(&w.level, int32(level))
+}
@@ -0,0 +1,299 @@
+package log4go
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const asyncQueueSizeDefault = 1024
+
+var errAsyncClosed = errors.New("async writer is closed")
+
+type asyncOp int
+
+const (
+	asyncFlush asyncOp = iota
+	asyncRotate
+	asyncSetPathPattern
+	asyncSync
+)
+
+type asyncCtrl struct {
+	op      asyncOp
+	pattern string
+	reply   chan error
+}
+
+type asyncItem struct {
+	r        *Record
+	enqueued time.Time
+}
+
+// AsyncWriterStats statistics of an async writer
+type AsyncWriterStats struct {
+	Backlog int           // records waiting in the queue
+	Written uint64        // records handed to the writer
+	Dropped uint64        // records dropped by the overflow policy
+	Lag     time.Duration // queue wait of the last written record
+	MaxLag  time.Duration // max queue wait since the writer started
+}
+
+// AsyncWriter wrap a writer with its own bounded queue and goroutine, so a
+// slow writer can not stall the others. Flush and Rotate are queued too,
+// they run after the records written before them.
+type AsyncWriter struct {
+	w             Writer
+	queue         chan asyncItem
+	ctrl          chan asyncCtrl
+	overflow      OverflowPolicy
+	overflowLevel int
+
+	written uint64
+	dropped uint64
+	lag     int64 // time.Duration, accessed atomically
+	maxLag  int64
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewAsyncWriter wrap w, size is the queue size, policy what to do when the
+// queue is full, level is used by OverflowDropBelow
+func NewAsyncWriter(w Writer, size int, policy OverflowPolicy, level int) *AsyncWriter {
+	if size <= 0 {
+		size = asyncQueueSizeDefault
+	}
+	return &AsyncWriter{
+		w:             w,
+		queue:         make(chan asyncItem, size),
+		ctrl:          make(chan asyncCtrl, 2),
+		overflow:      policy,
+		overflowLevel: level,
+		done:          make(chan struct{}),
+	}
+}
+
+// Level the level of the wrapped writer, -1 if it has no level
+func (w *AsyncWriter) Level() int {
+	if lw, ok := w.w.(Leveler); ok {
+		return lw.Level()
+	}
+	return -1
+}
+
+// SetLevel set the level of the wrapped writer
+func (w *AsyncWriter) SetLevel(level int) {
+	if lw, ok := w.w.(Leveler); ok {
+		lw.SetLevel(level)
+	}
+}
+
+// Unwrap the wrapped writer
+func (w *AsyncWriter) Unwrap() Writer {
+	return w.w
+}
+
+// Init init the wrapped writer and start the goroutine
+func (w *AsyncWriter) Init() error {
+	if err := w.w.Init(); err != nil {
+		return err
+	}
+	go w.run()
+	return nil
+}
+
+// Write queue a copy of the record, the record itself goes back to the pool.
+// The records below the level of the wrapped writer are not queued.
+func (w *AsyncWriter) Write(r *Record) error {
+	if lw, ok := w.w.(Leveler); ok && r.level < lw.Level() {
+		return nil
+	}
+	c := recordPool.Get().(*Record)
+	c.copyFrom(r)
+	item := asyncItem{r: c, enqueued: time.Now()}
+
+	if w.overflow == OverflowBlock || (w.overflow == OverflowDropBelow && r.level >= w.overflowLevel) {
+		w.queue <- item
+		return nil
+	}
+	for {
+		select {
+		case w.queue <- item:
+			return nil
+		default:
+		}
+
+		if w.overflow == OverflowDropOldest {
+			select {
+			case old := <-w.queue:
+				w.drop(old.r)
+			default:
+			}
+			continue
+		}
+		w.drop(c)
+		return nil
+	}
+}
+
+// Flush queue a flush, skipped if one is already pending
+func (w *AsyncWriter) Flush() error {
+	w.control(asyncFlush)
+	return nil
+}
+
+// Rotate queue a rotate, skipped if one is already pending
+func (w *AsyncWriter) Rotate() error {
+	w.control(asyncRotate)
+	return nil
+}
+
+// SetPathPattern set the path pattern of the wrapped writer on its goroutine, wait for the result
+func (w *AsyncWriter) SetPathPattern(pattern string) error {
+	reply := make(chan error, 1)
+	select {
+	case w.ctrl <- asyncCtrl{op: asyncSetPathPattern, pattern: pattern, reply: reply}:
+	case <-w.done:
+		return errAsyncClosed
+	}
+	select {
+	case err := <-reply:
+		return err
+	case <-w.done:
+		return errAsyncClosed
+	}
+}
+
+// Sync write the queued records and flush the wrapped writer, wait until done
+func (w *AsyncWriter) Sync() error {
+	reply := make(chan error, 1)
+	select {
+	case w.ctrl <- asyncCtrl{op: asyncSync, reply: reply}:
+	case <-w.done:
+		return nil
+	}
+	select {
+	case err := <-reply:
+		return err
+	case <-w.done:
+		return nil
+	}
+}
+
+// Stats the queue statistics
+func (w *AsyncWriter) Stats() AsyncWriterStats {
+	return AsyncWriterStats{
+		Backlog: len(w.queue),
+		Written: atomic.LoadUint64(&w.written),
+		Dropped: atomic.LoadUint64(&w.dropped),
+		Lag:     time.Duration(atomic.LoadInt64(&w.lag)),
+		MaxLag:  time.Duration(atomic.LoadInt64(&w.maxLag)),
+	}
+}
+
+// Close drain the queue, flush and close the wrapped writer, stop the goroutine
+func (w *AsyncWriter) Close() (err error) {
+	w.closeOnce.Do(func() {
+		close(w.queue)
+		<-w.done
+		if c, ok := w.w.(Closer); ok {
+			err = c.Close()
+		}
+	})
+	<-w.done
+	return err
+}
+
+func (w *AsyncWriter) control(op asyncOp) {
+	select {
+	case w.ctrl <- asyncCtrl{op: op}:
+	default:
+	}
+}
+
+func (w *AsyncWriter) drop(r *Record) {
+	atomic.AddUint64(&w.dropped, 1)
+	recordPool.Put(r)
+}
+
+func (w *AsyncWriter) run() {
+	defer close(w.done)
+	for {
+		select {
+		case item, ok := <-w.queue:
+			if !ok {
+				w.do(asyncCtrl{op: asyncFlush})
+				return
+			}
+			w.write(item)
+		case c := <-w.ctrl:
+			// the records queued before the control are written first
+			closed := w.drain()
+			w.do(c)
+			if closed {
+				w.do(asyncCtrl{op: asyncFlush})
+				return
+			}
+		}
+	}
+}
+
+// drain write the records in the queue, true if the queue is closed
+func (w *AsyncWriter) drain() bool {
+	for n := len(w.queue); n > 0; n-- {
+		item, ok := <-w.queue
+		if !ok {
+			return true
+		}
+		w.write(item)
+	}
+	return false
+}
+
+func (w *AsyncWriter) write(item asyncItem) {
+	lag := int64(time.Since(item.enqueued))
+	atomic.StoreInt64(&w.lag, lag)
+	if lag > atomic.LoadInt64(&w.maxLag) {
+		atomic.StoreInt64(&w.maxLag, lag)
+	}
+
+	if err := w.w.Write(item.r); err != nil {
+		writeError(item.r, err)
+	}
+	atomic.AddUint64(&w.written, 1)
+	recordPool.Put(item.r)
+}
+
+func (w *AsyncWriter) do(c asyncCtrl) {
+	switch c.op {
+	case asyncFlush:
+		if f, ok := w.w.(Flusher); ok {
+			if err := f.Flush(); err != nil {
+				logPrintln(err)
+			}
+		}
+	case asyncRotate:
+		if r, ok := w.w.(Rotater); ok {
+			if err := r.Rotate(); err != nil {
+				logPrintln(err)
+			}
+		}
+	case asyncSetPathPattern:
+		r, ok := w.w.(Rotater)
+		if !ok {
+			c.reply <- errors.New("writer does not support path pattern")
+			return
+		}
+		err := r.SetPathPattern(c.pattern)
+		if err == nil {
+			err = r.Rotate()
+		}
+		c.reply <- err
+	case asyncSync:
+		// the queued records are already written, see run
+		w.do(asyncCtrl{op: asyncFlush})
+		c.reply <- nil
+	}
+}
@@ -0,0 +1,146 @@
+package log4go
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockingWriter leveled writer blocking every Write until release is closed
+type blockingWriter struct {
+	memWriter
+	level   int
+	release chan struct{}
+	writing int32 // number of Write calls, accessed atomically
+}
+
+func (w *blockingWriter) Write(r *Record) error {
+	atomic.AddInt32(&w.writing, 1)
+	<-w.release
+	return w.memWriter.Write(r)
+}
+
+func (w *blockingWriter) Level() int {
+	return w.level
+}
+
+func (w *blockingWriter) SetLevel(level int) {
+	w.level = level
+}
+
+func TestAsyncWriterSkipsRecordsBelowLevel(t *testing.T) {
+	inner := &blockingWriter{level: ERROR, release: make(chan struct{})}
+	w := NewAsyncWriter(inner, 1, OverflowDropNewest, 0)
+	if err := w.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &Record{level: INFO, info: "below"}
+	for i := 0; i < 10; i++ {
+		if err := w.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if st := w.Stats(); st.Backlog != 0 || st.Dropped != 0 {
+		t.Fatalf("stats = %+v, want nothing queued or dropped", st)
+	}
+
+	if err := w.Write(&Record{level: ERROR, info: "kept"}); err != nil {
+		t.Fatal(err)
+	}
+	close(inner.release)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if msgs := inner.messages(); len(msgs) != 1 || msgs[0] != "kept" {
+		t.Fatalf("messages = %v", msgs)
+	}
+}
+
+func TestAsyncWriterSetPathPatternAfterClose(t *testing.T) {
+	w := NewAsyncWriter(&memWriter{}, 1, OverflowBlock, 0)
+	if err := w.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- w.SetPathPattern("app.log")
+	}()
+	select {
+	case err := <-done:
+		if err != errAsyncClosed {
+			t.Fatalf("err = %v, want %v", err, errAsyncClosed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("SetPathPattern blocked after Close")
+	}
+}
+
+// rotatingWriter blocking writer keeping the messages per file, Rotate opens the next file
+type rotatingWriter struct {
+	blockingWriter
+	mu    sync.Mutex
+	file  int
+	files map[int][]string
+}
+
+func (w *rotatingWriter) Write(r *Record) error {
+	atomic.AddInt32(&w.writing, 1)
+	<-w.release
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.files[w.file] = append(w.files[w.file], r.info)
+	return nil
+}
+
+func (w *rotatingWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.file++
+	return nil
+}
+
+func (w *rotatingWriter) SetPathPattern(pattern string) error {
+	return nil
+}
+
+func TestAsyncWriterRotateAfterQueuedRecords(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		inner := &rotatingWriter{
+			blockingWriter: blockingWriter{release: make(chan struct{})},
+			files:          make(map[int][]string),
+		}
+		w := NewAsyncWriter(inner, 8, OverflowBlock, 0)
+		if err := w.Init(); err != nil {
+			t.Fatal(err)
+		}
+
+		// "a" is held by the wrapped writer, "b" and "c" wait in the queue
+		for _, msg := range []string{"a", "b", "c"} {
+			if err := w.Write(&Record{level: INFO, info: msg}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for atomic.LoadInt32(&inner.writing) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		if err := w.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+		close(inner.release)
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		want := map[int][]string{0: {"a", "b", "c"}}
+		if !reflect.DeepEqual(inner.files, want) {
+			t.Fatalf("files = %v, want %v", inner.files, want)
+		}
+	}
+}
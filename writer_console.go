@@ -1,47 +1,63 @@
 package log4go
 
 import (
+	"bytes"
 	"fmt"
 	"os"
+	"sync/atomic"
 )
 
 type colorRecord Record
 
 func (r *colorRecord) String() string {
 	switch r.level {
+	case TRACE:
+		return fmt.Sprintf("\033[36m%s\033[0m [\033[37m%s\033[0m] \033[47;30m%s\033[0m %s%s\n",
+			r.time, LevelFlags[r.level], r.code, (*Record)(r).namedInfo(), fieldsString(r.fields))
+
 	case DEBUG:
-		return fmt.Sprintf("\033[36m%s\033[0m [\033[34m%s\033[0m] \033[47;30m%s\033[0m %s\n",
-			r.time, LevelFlags[r.level], r.code, r.info)
+		return fmt.Sprintf("\033[36m%s\033[0m [\033[34m%s\033[0m] \033[47;30m%s\033[0m %s%s\n",
+			r.time, LevelFlags[r.level], r.code, (*Record)(r).namedInfo(), fieldsString(r.fields))
 
 	case INFO:
-		return fmt.Sprintf("\033[36m%s\033[0m [\033[32m%s\033[0m] \033[47;30m%s\033[0m %s\n",
-			r.time, LevelFlags[r.level], r.code, r.info)
+		return fmt.Sprintf("\033[36m%s\033[0m [\033[32m%s\033[0m] \033[47;30m%s\033[0m %s%s\n",
+			r.time, LevelFlags[r.level], r.code, (*Record)(r).namedInfo(), fieldsString(r.fields))
 
 	case WARNING:
-		return fmt.Sprintf("\033[36m%s\033[0m [\033[33m%s\033[0m] \033[47;30m%s\033[0m %s\n",
-			r.time, LevelFlags[r.level], r.code, r.info)
+		return fmt.Sprintf("\033[36m%s\033[0m [\033[33m%s\033[0m] \033[47;30m%s\033[0m %s%s\n",
+			r.time, LevelFlags[r.level], r.code, (*Record)(r).namedInfo(), fieldsString(r.fields))
 
 	case ERROR:
-		return fmt.Sprintf("\033[36m%s\033[0m [\033[31m%s\033[0m] \033[47;30m%s\033[0m %s\n",
-			r.time, LevelFlags[r.level], r.code, r.info)
+		return fmt.Sprintf("\033[36m%s\033[0m [\033[31m%s\033[0m] \033[47;30m%s\033[0m %s%s\n",
+			r.time, LevelFlags[r.level], r.code, (*Record)(r).namedInfo(), fieldsString(r.fields))
 
-	case FATAL:
-		return fmt.Sprintf("\033[36m%s\033[0m [\033[35m%s\033[0m] \033[47;30m%s\033[0m %s\n",
-			r.time, LevelFlags[r.level], r.code, r.info)
+	case PANIC, FATAL:
+		return fmt.Sprintf("\033[36m%s\033[0m [\033[35m%s\033[0m] \033[47;30m%s\033[0m %s%s\n",
+			r.time, LevelFlags[r.level], r.code, (*Record)(r).namedInfo(), fieldsString(r.fields))
 	}
 
 	return ""
 }
 
+// colorString the colored record followed by its stack
+func (r *colorRecord) colorString() string {
+	if len(r.stack) == 0 {
+		return r.String()
+	}
+	return r.String() + stackString(r.stack)
+}
+
 // ConsoleWriter console writer define
 type ConsoleWriter struct {
-	config *ConfConsoleWriter
-	level  int
+	config    *ConfConsoleWriter
+	level     int32 // accessed atomically
+	formatter Formatter
+	buf       bytes.Buffer
 }
 
 // NewConsoleWriter create new console writer
 func NewConsoleWriter(conf *ConfConsoleWriter) *ConsoleWriter {
-	return &ConsoleWriter{config: conf, level: getLevel(conf.Level)}
+	return &ConsoleWriter{config: conf, level: int32(getLevel(conf.Level))}
 }
 
 // NewConsoleWriterWithLevel create new console writer with level
@@ -51,29 +67,50 @@ func NewConsoleWriterWithLevel(level int, conf *ConfConsoleWriter) *ConsoleWrite
 	// maxLevel >= 1 always true
 	maxLevel = maxLevel - 1
 
-	if level >= defaultLevel && level <= maxLevel {
+	if level >= TRACE && level <= maxLevel {
 		defaultLevel = level
 	}
 	return &ConsoleWriter{
-		level:  defaultLevel,
+		level:  int32(defaultLevel),
 		config: conf,
 	}
 }
 
 // Write console write
 func (w *ConsoleWriter) Write(r *Record) (err error) {
-	if r.level < w.level {
+	if int32(r.level) < atomic.LoadInt32(&w.level) {
 		return nil
 	}
-	if w.config.Color {
-		_, err = fmt.Fprint(os.Stdout, ((*colorRecord)(r)).String())
+	if w.formatter != nil {
+		formatRecord(&w.buf, w.formatter, r)
+		_, err = os.Stdout.Write(w.buf.Bytes())
+	} else if w.config.Color {
+		_, err = fmt.Fprint(os.Stdout, ((*colorRecord)(r)).colorString())
 	} else {
 		_, err = fmt.Fprint(os.Stdout, r.String())
 	}
 	return nil
 }
 
-// Init console init without implement
-func (w *ConsoleWriter) Init() error {
-	return nil
+// SetFormatter console set formatter, replace the pattern of the config
+func (w *ConsoleWriter) SetFormatter(f Formatter) {
+	w.formatter = f
+}
+
+// Init console init the formatter
+func (w *ConsoleWriter) Init() (err error) {
+	if w.formatter == nil {
+		w.formatter, err = newFormatter(w.config.Format, w.config.Pattern)
+	}
+	return err
+}
+
+// Level console writer get the level
+func (w *ConsoleWriter) Level() int {
+	return int(atomic.LoadInt32(&w.level))
+}
+
+// SetLevel console writer set the level, safe to call while writing
+func (w *ConsoleWriter) SetLevel(level int) {
+	atomic.StoreInt32(&w.level, int32(level))
 }
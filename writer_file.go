@@ -7,6 +7,13 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -15,17 +22,26 @@ var pathVariableTable map[byte]func(*time.Time) int
 // FileWriter file writer define
 type FileWriter struct {
 	config        *ConfFileWriter
-	level         int
+	level         int32 // accessed atomically
 	pathFmt       string
+	filePath      string
 	file          *os.File
 	fileBufWriter *bufio.Writer
+	size          int64 // bytes in the current file
 	actions       []func(*time.Time) int
 	variables     []interface{}
+	compressor    *fileCompressor
+	backupLock    sync.Mutex // guard renaming and removing the rotated files
+	sweepLock     sync.Mutex
+	sweeping      bool   // set while the retention sweeper runs, guarded by sweepLock
+	sweepPath     string // opened file of the sweep requested meanwhile, guarded by sweepLock
+	formatter     Formatter
+	buf           bytes.Buffer
 }
 
 // NewFileWriter create new file writer
 func NewFileWriter(conf *ConfFileWriter) *FileWriter {
-	return &FileWriter{level: getLevel(conf.Level), config: conf}
+	return &FileWriter{level: int32(getLevel(conf.Level)), config: conf}
 }
 
 // NewFileWriterWithLevel create new file writer with level
@@ -35,39 +51,86 @@ func NewFileWriterWithLevel(level int, conf *ConfFileWriter) *FileWriter {
 	// maxLevel >= 1 always true
 	maxLevel = maxLevel - 1
 
-	if level >= defaultLevel && level <= maxLevel {
+	if level >= TRACE && level <= maxLevel {
 		defaultLevel = level
 	}
 	return &FileWriter{
-		level:  defaultLevel,
+		level:  int32(defaultLevel),
 		config: conf,
 	}
 }
 
 // Init for file writer
 func (w *FileWriter) Init() error {
-	if err := w.setPathPattern(w.config.PathPattern); err != nil {
+	if w.formatter == nil {
+		f, err := newFormatter(w.config.Format, w.config.Pattern)
+		if err != nil {
+			return err
+		}
+		w.formatter = f
+	}
+	if err := w.SetPathPattern(w.config.PathPattern); err != nil {
 		return err
 	}
-	return w.Rotate()
+	if w.config.Compress != "" && w.compressor == nil {
+		c, err := newFileCompressor(w.config.Compress)
+		if err != nil {
+			return err
+		}
+		w.compressor = c
+		go w.compressLoop(c)
+	}
+	if err := w.Rotate(); err != nil {
+		return err
+	}
+	w.sweep(w.filePath)
+	if w.compressor != nil {
+		w.compressLeftovers()
+	}
+	return nil
 }
 
 // Write for file writer
 func (w *FileWriter) Write(r *Record) error {
-	if r.level < w.level {
+	if int32(r.level) < atomic.LoadInt32(&w.level) {
 		return nil
 	}
 	if w.fileBufWriter == nil {
 		return errors.New("no opened file")
 	}
-	if _, err := w.fileBufWriter.WriteString(r.String()); err != nil {
-		return err
+	formatRecord(&w.buf, w.formatter, r)
+	if max := w.config.MaxSize * 1024 * 1024; max > 0 && w.size > 0 && w.size+int64(w.buf.Len()) > max {
+		if err := w.rotateBySize(); err != nil {
+			return err
+		}
 	}
-	return nil
+	n, err := w.fileBufWriter.Write(w.buf.Bytes())
+	w.size += int64(n)
+	return err
+}
+
+// Close for file writer, flush and close the file, pending compressions are finished in background
+func (w *FileWriter) Close() error {
+	err := w.closeFile()
+	w.filePath = ""
+	if w.compressor != nil {
+		close(w.compressor.queue)
+		w.compressor = nil
+	}
+	return err
 }
 
-// SetPathPattern for file writer
-func (w *FileWriter) setPathPattern(pattern string) error {
+// SetFormatter for file writer, replace the pattern of the config
+func (w *FileWriter) SetFormatter(f Formatter) {
+	w.formatter = f
+}
+
+// SetPathPattern for file writer, the file is reopened by the next Rotate
+func (w *FileWriter) SetPathPattern(pattern string) error {
+	w.actions = nil
+	w.variables = nil
+	w.filePath = ""
+
 	n := 0
 	for _, c := range pattern {
 		if c == '%' {
@@ -119,24 +182,91 @@ func (w *FileWriter) Rotate() error {
 		}
 	}
 
-	if !rotate {
+	if !rotate && w.filePath != "" {
 		return nil
 	}
 
+	prevPath := w.filePath
+	if err := w.closeFile(); err != nil {
+		return err
+	}
+
+	if err := w.openFile(fmt.Sprintf(w.pathFmt, w.variables...)); err != nil {
+		// tried again by the next Rotate
+		w.filePath = ""
+		return err
+	}
+	if prevPath != "" && prevPath != w.filePath {
+		w.compress(prevPath)
+	}
+	if rotate {
+		go w.sweep(w.filePath)
+	}
+	return nil
+}
+
+// rotateBySize rename app.log to app.log.1, the older app.log.N to app.log.N+1.
+// If a rename fails, the writer keeps writing to app.log and the rotation is
+// tried again by the next record exceeding MaxSize.
+func (w *FileWriter) rotateBySize() error {
+	filePath := w.filePath
+	if err := w.closeFile(); err != nil {
+		return err
+	}
+
+	w.backupLock.Lock()
+	err := shiftBackups(filePath)
+	w.backupLock.Unlock()
+	if err != nil {
+		if oerr := w.openFile(filePath); oerr != nil {
+			// opened again by the next Rotate
+			w.filePath = ""
+		}
+		return err
+	}
+
+	if err := w.openFile(filePath); err != nil {
+		w.filePath = ""
+		return err
+	}
+	w.compress(filePath + ".1")
+	go w.sweep(w.filePath)
+	return nil
+}
+
+// shiftBackups rename app.log.N to app.log.N+1 from the oldest, then app.log
+// to app.log.1, the backupLock is held
+func shiftBackups(filePath string) error {
+	n := 1
+	for backupExists(filePath, n) {
+		n++
+	}
+	for ; n > 1; n-- {
+		if err := renameBackup(filePath, n-1, n); err != nil {
+			return err
+		}
+	}
+	return os.Rename(filePath, filePath+".1")
+}
+
+func (w *FileWriter) closeFile() error {
 	if w.fileBufWriter != nil {
 		if err := w.fileBufWriter.Flush(); err != nil {
 			return err
 		}
+		w.fileBufWriter = nil
 	}
 
 	if w.file != nil {
 		if err := w.file.Close(); err != nil {
 			return err
 		}
+		w.file = nil
 	}
+	return nil
+}
 
-	filePath := fmt.Sprintf(w.pathFmt, w.variables...)
-
+func (w *FileWriter) openFile(filePath string) error {
 	if err := os.MkdirAll(path.Dir(filePath), 0755); err != nil {
 		if !os.IsExist(err) {
 			return err
@@ -148,6 +278,11 @@ func (w *FileWriter) Rotate() error {
 		return err
 	}
 	w.file = file
+	w.filePath = filePath
+	w.size = 0
+	if fi, err := file.Stat(); err == nil {
+		w.size = fi.Size()
+	}
 
 	if w.fileBufWriter = bufio.NewWriterSize(w.file, 8192); w.fileBufWriter == nil {
 		return errors.New("new fileBufWriter failed")
@@ -164,6 +299,118 @@ func (w *FileWriter) Flush() error {
 	return nil
 }
 
+// sweep delete the rotated files exceeding MaxBackups or older than MaxAge,
+// files left by the time pattern are rotated files too, current is the opened
+// file. A sweep requested while one runs is done by the running one after it.
+func (w *FileWriter) sweep(current string) {
+	if w.config.MaxBackups <= 0 && w.config.MaxAge <= 0 {
+		return
+	}
+	w.sweepLock.Lock()
+	w.sweepPath = current
+	if w.sweeping {
+		w.sweepLock.Unlock()
+		return
+	}
+	w.sweeping = true
+	for w.sweepPath != "" {
+		current = w.sweepPath
+		w.sweepPath = ""
+		w.sweepLock.Unlock()
+		w.sweepFiles(current)
+		w.sweepLock.Lock()
+	}
+	w.sweeping = false
+	w.sweepLock.Unlock()
+}
+
+// sweepFiles delete the rotated files out of the retention, no file is renamed meanwhile
+func (w *FileWriter) sweepFiles(current string) {
+	w.backupLock.Lock()
+	defer w.backupLock.Unlock()
+
+	files, err := w.rotatedFiles(current)
+	if err != nil {
+		logPrintln(err)
+		return
+	}
+
+	cutoff := time.Now().Add(-time.Duration(w.config.MaxAge) * 24 * time.Hour)
+	for i, f := range files {
+		if (w.config.MaxBackups > 0 && i >= w.config.MaxBackups) ||
+			(w.config.MaxAge > 0 && f.ModTime().Before(cutoff)) {
+			if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+				logPrintln(err)
+			}
+		}
+	}
+}
+
+type rotatedFile struct {
+	os.FileInfo
+	path string
+}
+
+// rotatedFiles list the rotated files, newest first: the files of the path
+// pattern, app.log.N and their compressed app.log.N.gz or .zst
+func (w *FileWriter) rotatedFiles(current string) ([]rotatedFile, error) {
+	current = filepath.Clean(current)
+	matches, err := filepath.Glob(convertPatternToGlob(w.config.PathPattern) + "*")
+	if err != nil {
+		return nil, err
+	}
+	re := rotatedFileRegexp(w.config.PathPattern)
+
+	files := make([]rotatedFile, 0, len(matches))
+	for _, m := range matches {
+		m = filepath.Clean(m)
+		if m == current || !re.MatchString(m) {
+			continue
+		}
+		fi, err := os.Stat(m)
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		files = append(files, rotatedFile{FileInfo: fi, path: m})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if ti, tj := files[i].ModTime(), files[j].ModTime(); !ti.Equal(tj) {
+			return ti.After(tj)
+		}
+		return backupIndex(files[i].path) < backupIndex(files[j].path)
+	})
+	return files, nil
+}
+
+// rotatedFileRegexp match the files of the path pattern, app.log.N, and their compressed files
+func rotatedFileRegexp(pattern string) *regexp.Regexp {
+	pattern = filepath.Clean(pattern)
+	var b strings.Builder
+	b.WriteByte('^')
+	literal := 0
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' || i+1 >= len(pattern) {
+			continue
+		}
+		if _, ok := pathVariableTable[pattern[i+1]]; ok {
+			b.WriteString(regexp.QuoteMeta(pattern[literal:i]))
+			b.WriteString(`\d+`)
+			literal = i + 2
+			i++
+		}
+	}
+	b.WriteString(regexp.QuoteMeta(pattern[literal:]))
+	b.WriteString(`(\.\d+)?(`)
+	for i, ext := range compressExtList() {
+		if i > 0 {
+			b.WriteByte('|')
+		}
+		b.WriteString(regexp.QuoteMeta(ext))
+	}
+	b.WriteString(`)?$`)
+	return regexp.MustCompile(b.String())
+}
+
 func getYear(now *time.Time) int {
 	return now.Year()
 }
@@ -193,6 +440,37 @@ func convertPatternToFmt(pattern []byte) string {
 	return string(pattern)
 }
 
+// backupIndex the N of app.log.N or app.log.N.gz, 0 if not numbered
+func backupIndex(p string) int {
+	p = trimCompressExt(p)
+	if i := strings.LastIndexByte(p, '.'); i >= 0 {
+		if n, err := strconv.Atoi(p[i+1:]); err == nil {
+			return n
+		}
+	}
+	return 0
+}
+
+// convertPatternToGlob replace the time variables with *, escape the glob meta characters
+func convertPatternToGlob(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		if c == '%' && i+1 < len(pattern) {
+			if _, ok := pathVariableTable[pattern[i+1]]; ok {
+				b.WriteByte('*')
+				i++
+				continue
+			}
+		}
+		if c == '*' || c == '?' || c == '[' || c == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
 func init() {
 	pathVariableTable = make(map[byte]func(*time.Time) int, 5)
 	pathVariableTable['Y'] = getYear
@@ -201,3 +479,13 @@ func init() {
 	pathVariableTable['H'] = getHour
 	pathVariableTable['m'] = getMin
 }
+
+// Level file writer get the level
+func (w *FileWriter) Level() int {
+	return int(atomic.LoadInt32(&w.level))
+}
+
+// SetLevel file writer set the level, safe to call while writing
+func (w *FileWriter) SetLevel(level int) {
+	atomic.StoreInt32(&w.level, int32(level))
+}
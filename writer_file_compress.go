@@ -0,0 +1,258 @@
+package log4go
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// compress algorithms for ConfFileWriter.Compress
+const (
+	CompressGzip = "gzip"
+	CompressZstd = "zstd"
+)
+
+const compressQueueSize = 64
+
+// compressTempStale age of a temp file left by a crash, a younger one may be
+// written by the writer replaced by a config reload
+const compressTempStale = time.Minute
+
+// compressExts extensions of the compressed rotated files
+var compressExts = map[string]string{
+	CompressGzip: ".gz",
+	CompressZstd: ".zst",
+}
+
+// fileCompressor compress the rotated files on its own goroutine
+type fileCompressor struct {
+	algorithm string
+	ext       string
+	queue     chan *os.File
+}
+
+func newFileCompressor(algorithm string) (*fileCompressor, error) {
+	algorithm = strings.ToLower(strings.TrimSpace(algorithm))
+	ext, ok := compressExts[algorithm]
+	if !ok {
+		return nil, errors.New("invalid compress algorithm (" + algorithm + ")")
+	}
+	return &fileCompressor{
+		algorithm: algorithm,
+		ext:       ext,
+		queue:     make(chan *os.File, compressQueueSize),
+	}, nil
+}
+
+// compress queue the closed file, never block the writer. The file is opened
+// here as app.log.1 may be shifted to app.log.2 before the worker gets it
+func (w *FileWriter) compress(filePath string) {
+	if w.compressor == nil {
+		return
+	}
+	src, err := os.Open(filePath)
+	if err != nil {
+		logPrintln(err)
+		return
+	}
+	select {
+	case w.compressor.queue <- src:
+	default:
+		_ = src.Close()
+		logPrintf("compress queue is full, %s is left uncompressed until restart\n", filePath)
+	}
+}
+
+// compressLeftovers queue the rotated files left uncompressed by a crash, remove
+// the partial temp files not written for compressTempStale
+func (w *FileWriter) compressLeftovers() {
+	pattern := convertPatternToGlob(w.config.PathPattern)
+	temps, _ := filepath.Glob(filepath.Join(filepath.Dir(pattern), "."+filepath.Base(pattern)+"*.tmp"))
+	for _, t := range temps {
+		if fi, err := os.Stat(t); err != nil || time.Since(fi.ModTime()) < compressTempStale {
+			continue
+		}
+		if err := os.Remove(t); err != nil {
+			logPrintln(err)
+		}
+	}
+
+	files, err := w.rotatedFiles(w.filePath)
+	if err != nil {
+		logPrintln(err)
+		return
+	}
+	// oldest first
+	for i := len(files) - 1; i >= 0; i-- {
+		if !isCompressed(files[i].path) {
+			w.compress(files[i].path)
+		}
+	}
+}
+
+func (w *FileWriter) compressLoop(c *fileCompressor) {
+	for src := range c.queue {
+		if err := w.compressFile(c, src); err != nil {
+			logPrintln(err)
+		}
+		_ = src.Close()
+	}
+}
+
+// compressFile compress to a temp file then rename it, the source may be
+// renamed by the size rotation meanwhile, it is located again before the rename.
+// The temp file name is unique, the writer replaced by a config reload may
+// compress the same file.
+func (w *FileWriter) compressFile(c *fileCompressor, src *os.File) error {
+	filePath := src.Name()
+	fi, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst, err := ioutil.TempFile(filepath.Dir(filePath), "."+filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := dst.Name()
+	if err = dst.Chmod(0644); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err = c.copy(dst, src); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+
+	w.backupLock.Lock()
+	defer w.backupLock.Unlock()
+
+	current := locateBackup(filePath, fi)
+	if current == "" { // removed by the sweeper, or compressed by another writer
+		return os.Remove(tmpPath)
+	}
+	if err = os.Rename(tmpPath, current+c.ext); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	// after the rename, a temp file looking stale would be removed by compressLeftovers
+	if err = os.Chtimes(current+c.ext, fi.ModTime(), fi.ModTime()); err != nil {
+		logPrintln(err)
+	}
+	return os.Remove(current)
+}
+
+// copy compress src to dst, dst is closed
+func (c *fileCompressor) copy(dst *os.File, src io.Reader) (err error) {
+	defer func() {
+		if cerr := dst.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	var zw io.WriteCloser
+	switch c.algorithm {
+	case CompressZstd:
+		if zw, err = zstd.NewWriter(dst); err != nil {
+			return err
+		}
+	default:
+		zw = gzip.NewWriter(dst)
+	}
+	if _, err = io.Copy(zw, src); err != nil {
+		_ = zw.Close()
+		return err
+	}
+	if err = zw.Close(); err != nil {
+		return err
+	}
+	return dst.Sync()
+}
+
+// locateBackup find where the file fi is now, app.log.N may be shifted to app.log.N+k
+func locateBackup(filePath string, fi os.FileInfo) string {
+	if sameFile(filePath, fi) {
+		return filePath
+	}
+	n := backupIndex(filePath)
+	if n == 0 {
+		return ""
+	}
+	base := strings.TrimSuffix(filePath, "."+strconv.Itoa(n))
+	for ; ; n++ {
+		p := base + "." + strconv.Itoa(n)
+		if sameFile(p, fi) {
+			return p
+		}
+		if !backupExists(base, n) {
+			return ""
+		}
+	}
+}
+
+func sameFile(filePath string, fi os.FileInfo) bool {
+	cur, err := os.Stat(filePath)
+	return err == nil && os.SameFile(cur, fi)
+}
+
+// backupExists report whether app.log.N exists, compressed or not
+func backupExists(base string, n int) bool {
+	p := base + "." + strconv.Itoa(n)
+	if _, err := os.Stat(p); err == nil {
+		return true
+	}
+	for _, ext := range compressExts {
+		if _, err := os.Stat(p + ext); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// renameBackup rename app.log.from to app.log.to, compressed or not
+func renameBackup(base string, from, to int) error {
+	src, dst := base+"."+strconv.Itoa(from), base+"."+strconv.Itoa(to)
+	for _, ext := range append([]string{""}, compressExtList()...) {
+		// the sweeper may have removed it meanwhile
+		if err := os.Rename(src+ext, dst+ext); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func compressExtList() []string {
+	exts := make([]string, 0, len(compressExts))
+	for _, ext := range compressExts {
+		exts = append(exts, ext)
+	}
+	return exts
+}
+
+func isCompressed(p string) bool {
+	for _, ext := range compressExts {
+		if strings.HasSuffix(p, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// trimCompressExt app.log.1.gz to app.log.1
+func trimCompressExt(p string) string {
+	for _, ext := range compressExts {
+		if strings.HasSuffix(p, ext) {
+			return strings.TrimSuffix(p, ext)
+		}
+	}
+	return p
+}
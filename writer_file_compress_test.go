@@ -0,0 +1,133 @@
+package log4go
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// decompressTestFile content of the gzip or zstd file
+func decompressTestFile(t *testing.T, p string) string {
+	t.Helper()
+	f, err := os.Open(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var r io.Reader
+	if strings.HasSuffix(p, ".zst") {
+		d, err := zstd.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		r = d
+	} else {
+		g, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = g
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// waitTestFile wait until the file exists, or is removed if not exists
+func waitTestFile(t *testing.T, p string, exists bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for testFileExists(p) != exists {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s exists %v, files %v", p, !exists, listDir(t, filepath.Dir(p)))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func testFileExists(p string) bool {
+	_, err := os.Stat(p)
+	return err == nil
+}
+
+func TestFileWriterCompressRotated(t *testing.T) {
+	for algorithm, ext := range compressExts {
+		dir := tempDir(t)
+		p := filepath.Join(dir, "app.log")
+		w := newTestFileWriter(t, &ConfFileWriter{PathPattern: p, MaxSize: 1, Compress: algorithm})
+
+		for _, c := range []byte("abcd") {
+			if err := w.Write(bigRecord(300*1024, c)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		waitTestFile(t, p+".1"+ext, true)
+		// the source is removed last
+		waitTestFile(t, p+".1", false)
+
+		content := decompressTestFile(t, p+".1"+ext)
+		lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+		if len(lines) != 3 {
+			t.Fatalf("%s: %d lines, want 3", algorithm, len(lines))
+		}
+		for i, c := range "abc" {
+			if !strings.HasSuffix(lines[i], strings.Repeat(string(c), 300*1024)) {
+				t.Errorf("%s: line %d is not the record %c", algorithm, i, c)
+			}
+		}
+		for _, name := range listDir(t, dir) {
+			if strings.HasSuffix(name, ".tmp") {
+				t.Errorf("%s: temp file %s left", algorithm, name)
+			}
+		}
+	}
+}
+
+func TestFileWriterCompressLeftovers(t *testing.T) {
+	dir := tempDir(t)
+	p := filepath.Join(dir, "app.log")
+	old := time.Now().Add(-time.Hour)
+	writeTestFile(t, p+".1", "left by a crash\n", old)
+	writeTestFile(t, filepath.Join(dir, ".app.log.1.123.tmp"), "partial", old)
+	// written by the writer replaced by a reload
+	writeTestFile(t, filepath.Join(dir, ".app.log.2.456.tmp"), "partial", time.Now())
+
+	newTestFileWriter(t, &ConfFileWriter{PathPattern: p, Compress: CompressGzip})
+	waitTestFile(t, p+".1", false)
+
+	if got := decompressTestFile(t, p+".1.gz"); got != "left by a crash\n" {
+		t.Errorf("app.log.1.gz = %q", got)
+	}
+	fi, err := os.Stat(p + ".1.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.ModTime().Equal(old) {
+		t.Errorf("mod time = %v, want the one of app.log.1 %v", fi.ModTime(), old)
+	}
+	if testFileExists(filepath.Join(dir, ".app.log.1.123.tmp")) {
+		t.Error("stale temp file not removed")
+	}
+	if !testFileExists(filepath.Join(dir, ".app.log.2.456.tmp")) {
+		t.Error("temp file being written removed")
+	}
+}
+
+func TestFileWriterInvalidCompress(t *testing.T) {
+	w := NewFileWriterWithLevel(TRACE, &ConfFileWriter{PathPattern: filepath.Join(tempDir(t), "app.log"), Compress: "lz4"})
+	if err := w.Init(); err == nil {
+		_ = w.Close()
+		t.Fatal("lz4 accepted")
+	}
+}
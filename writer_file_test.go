@@ -0,0 +1,235 @@
+package log4go
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tempDir like t.TempDir, which needs go1.15
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "log4go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return dir
+}
+
+func newTestFileWriter(t *testing.T, conf *ConfFileWriter) *FileWriter {
+	t.Helper()
+	w := NewFileWriterWithLevel(TRACE, conf)
+	if err := w.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	return w
+}
+
+func writeTestFile(t *testing.T, p string, content string, modTime time.Time) {
+	t.Helper()
+	if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(p, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(t *testing.T, p string) string {
+	t.Helper()
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func listDir(t *testing.T, dir string) []string {
+	t.Helper()
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, fi := range infos {
+		names = append(names, fi.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestFileWriterRotateBySizeNumbering(t *testing.T) {
+	dir := tempDir(t)
+	p := filepath.Join(dir, "app.log")
+	w := newTestFileWriter(t, &ConfFileWriter{PathPattern: p})
+
+	for _, content := range []string{"first", "second", "third"} {
+		if _, err := w.fileBufWriter.WriteString(content); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.rotateBySize(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := map[string]string{"app.log": "", "app.log.1": "third", "app.log.2": "second", "app.log.3": "first"}
+	if got := listDir(t, dir); strings.Join(got, ",") != "app.log,app.log.1,app.log.2,app.log.3" {
+		t.Fatalf("files = %v", got)
+	}
+	for name, content := range want {
+		if got := readTestFile(t, filepath.Join(dir, name)); got != content {
+			t.Errorf("%s = %q, want %q", name, got, content)
+		}
+	}
+}
+
+func TestFileWriterMaxBackups(t *testing.T) {
+	dir := tempDir(t)
+	p := filepath.Join(dir, "app.log")
+	now := time.Now()
+	for i, name := range []string{"app.log.1", "app.log.2.gz", "app.log.3", "app.log.4.zst"} {
+		writeTestFile(t, filepath.Join(dir, name), name, now.Add(-time.Duration(i+1)*time.Minute))
+	}
+	// not written by the logger, never removed
+	for _, name := range []string{"app.log.bak", "app.logger.conf", "app.log.1.txt"} {
+		writeTestFile(t, filepath.Join(dir, name), name, now.Add(-time.Hour))
+	}
+
+	newTestFileWriter(t, &ConfFileWriter{PathPattern: p, MaxBackups: 2})
+
+	want := "app.log,app.log.1,app.log.1.txt,app.log.2.gz,app.log.bak,app.logger.conf"
+	if got := strings.Join(listDir(t, dir), ","); got != want {
+		t.Fatalf("files = %s, want %s", got, want)
+	}
+}
+
+func TestFileWriterMaxAge(t *testing.T) {
+	dir := tempDir(t)
+	p := filepath.Join(dir, "app-%Y%M%D.log")
+	now := time.Now()
+	files := map[string]time.Duration{
+		"app-20200101.log":      -10 * 24 * time.Hour,
+		"app-20200102.log.1.gz": -5 * 24 * time.Hour,
+		"app-20200103.log":      -time.Hour,
+		"app-20200104.log.2":    -time.Hour,
+		"app-old.log":           -10 * 24 * time.Hour, // not of the pattern
+	}
+	for name, age := range files {
+		writeTestFile(t, filepath.Join(dir, name), name, now.Add(age))
+	}
+
+	w := newTestFileWriter(t, &ConfFileWriter{PathPattern: p, MaxAge: 3})
+
+	want := []string{"app-20200103.log", "app-20200104.log.2", "app-old.log", filepath.Base(w.filePath)}
+	sort.Strings(want)
+	if got := listDir(t, dir); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("files = %v, want %v", got, want)
+	}
+}
+
+func TestFileWriterSweepRequestedWhileRunning(t *testing.T) {
+	dir := tempDir(t)
+	p := filepath.Join(dir, "app.log")
+	w := newTestFileWriter(t, &ConfFileWriter{PathPattern: p, MaxBackups: 1})
+
+	w.sweepLock.Lock()
+	w.sweeping = true
+	w.sweepLock.Unlock()
+	// returns at once, the running sweep does it
+	w.sweep(p)
+
+	w.sweepLock.Lock()
+	pending := w.sweepPath
+	w.sweeping = false
+	w.sweepLock.Unlock()
+	if pending != p {
+		t.Fatalf("pending sweep = %q, want %q", pending, p)
+	}
+}
+
+func TestRotatedFileRegexp(t *testing.T) {
+	re := rotatedFileRegexp("log/app-%Y%M%D.log")
+	for p, want := range map[string]bool{
+		"log/app-20240101.log":        true,
+		"log/app-20240101.log.3":      true,
+		"log/app-20240101.log.3.gz":   true,
+		"log/app-20240101.log.zst":    true,
+		"log/app-20240101.log.bak":    false,
+		"log/app-20240101.logger":     false,
+		"log/app-x.log":               false,
+		"log/app-20240101.log.3.gz.1": false,
+	} {
+		if got := re.MatchString(p); got != want {
+			t.Errorf("match %s = %v, want %v", p, got, want)
+		}
+	}
+}
+
+// bigRecord record of about size bytes
+func bigRecord(size int, c byte) *Record {
+	return &Record{level: INFO, info: strings.Repeat(string(c), size)}
+}
+
+func TestFileWriterWriteRotatesBySize(t *testing.T) {
+	dir := tempDir(t)
+	p := filepath.Join(dir, "app.log")
+	w := newTestFileWriter(t, &ConfFileWriter{PathPattern: p, MaxSize: 1})
+
+	// 3 records fit in 1MB, the 4th goes to a new file
+	for _, c := range []byte("abcd") {
+		if err := w.Write(bigRecord(300*1024, c)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(listDir(t, dir), ","); got != "app.log,app.log.1" {
+		t.Fatalf("files = %s", got)
+	}
+	old := readTestFile(t, p+".1")
+	if strings.Count(old, "\n") != 3 || !strings.Contains(old, "aaa") || strings.Contains(old, "ddd") {
+		t.Errorf("app.log.1 has %d lines", strings.Count(old, "\n"))
+	}
+	if cur := readTestFile(t, p); strings.Count(cur, "\n") != 1 || !strings.Contains(cur, "ddd") {
+		t.Errorf("app.log has %d lines", strings.Count(cur, "\n"))
+	}
+}
+
+func TestFileWriterRotateFailureKeepsWriting(t *testing.T) {
+	dir := tempDir(t)
+	p := filepath.Join(dir, "app.log")
+	w := newTestFileWriter(t, &ConfFileWriter{PathPattern: p, MaxSize: 1})
+
+	for _, c := range []byte("abc") {
+		if err := w.Write(bigRecord(300*1024, c)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// app.log can not be renamed any more
+	if err := os.Remove(p); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(bigRecord(300*1024, 'd')); err == nil {
+		t.Fatal("the failed rotation is not reported")
+	}
+	if err := w.Write(bigRecord(10, 'e')); err != nil {
+		t.Fatalf("write after the failed rotation: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if cur := readTestFile(t, p); !strings.Contains(cur, "eeeeeeeeee") {
+		t.Errorf("app.log = %q", cur)
+	}
+	if err := w.Rotate(); err != nil || w.fileBufWriter == nil {
+		t.Errorf("Rotate = %v, file opened %v", err, w.fileBufWriter != nil)
+	}
+}
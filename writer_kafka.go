@@ -1,9 +1,11 @@
 package log4go
 
 import (
-	"encoding/json"
-	"log"
-	"time"
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"sync/atomic"
 
 	"github.com/Shopify/sarama"
 )
@@ -12,7 +14,7 @@ const timestampFormat = "2006-01-02T15:04:05.000+0800"
 
 // KafKaWriter kafka writer
 type KafKaWriter struct {
-	level    int
+	level    int32 // accessed atomically
 	producer sarama.SyncProducer
 	messages chan *sarama.ProducerMessage
 	conf     *ConfKafKaWriter
@@ -20,6 +22,9 @@ type KafKaWriter struct {
 	run  bool // avoid the block with no running kafka writer
 	quit chan struct{}
 	stop chan struct{}
+
+	formatter *JSONFormatter
+	buf       bytes.Buffer
 }
 
 // NewKafKaWriter new kafka writer
@@ -28,7 +33,7 @@ func NewKafKaWriter(conf *ConfKafKaWriter) *KafKaWriter {
 		conf:  conf,
 		quit:  make(chan struct{}),
 		stop:  make(chan struct{}),
-		level: getLevel(conf.Level),
+		level: int32(getLevel(conf.Level)),
 	}
 }
 
@@ -39,7 +44,7 @@ func NewKafKaWriterWithWriter(level int, conf *ConfKafKaWriter) *KafKaWriter {
 	// maxLevel >= 1 always true
 	maxLevel = maxLevel - 1
 
-	if level >= defaultLevel && level <= maxLevel {
+	if level >= TRACE && level <= maxLevel {
 		defaultLevel = level
 	}
 
@@ -47,8 +52,34 @@ func NewKafKaWriterWithWriter(level int, conf *ConfKafKaWriter) *KafKaWriter {
 		conf:  conf,
 		quit:  make(chan struct{}),
 		stop:  make(chan struct{}),
-		level: defaultLevel,
+		level: int32(defaultLevel),
+	}
+}
+
+// newKafKaFormatter json formatter producing the KafKaMSGFields schema
+func newKafKaFormatter(msg *KafKaMSGFields) *JSONFormatter {
+	f := &JSONFormatter{
+		TimeKey:     "timestamp",
+		LevelKey:    "level",
+		CallerKey:   "file",
+		MessageKey:  "message",
+		StackKey:    "stack",
+		LoggerKey:   "logger",
+		TimeLayout:  timestampFormat,
+		UnixTimeKey: "now",
+		StaticFields: []Field{
+			{Key: "es_index", Value: msg.ESIndex},
+			{Key: "server_ip", Value: msg.ServerIP},
+			{Key: "public_ip", Value: msg.PublicIP},
+		},
+	}
+	for key, v := range msg.ExtraFields {
+		f.ExtraFields = append(f.ExtraFields, Field{Key: key, Value: v})
 	}
+	sort.Slice(f.ExtraFields, func(i, j int) bool {
+		return f.ExtraFields[i].Key < f.ExtraFields[j].Key
+	})
+	return f
 }
 
 // Init service for Record
@@ -62,7 +93,7 @@ func (k *KafKaWriter) Init() error {
 
 // Write service for Record
 func (k *KafKaWriter) Write(r *Record) error {
-	if r.level < k.level {
+	if int32(r.level) < atomic.LoadInt32(&k.level) {
 		return nil
 	}
 
@@ -70,41 +101,14 @@ func (k *KafKaWriter) Write(r *Record) error {
 	if logMsg == "" {
 		return nil
 	}
-	data := k.conf.MSG
-	// timestamp, level
-	data.Level = LevelFlags[r.level]
-	now := time.Now()
-	data.Now = now.Unix()
-	data.Timestamp = now.Format(timestampFormat)
-	data.Message = logMsg
-	data.Code = r.code
-
-	byteData, err := json.Marshal(data)
-	if err != nil {
-		return err
+	if k.formatter == nil {
+		k.formatter = newKafKaFormatter(&k.conf.MSG)
 	}
-
-	var structData map[string]interface{}
-	err = json.Unmarshal(byteData, &structData)
-	if err != nil {
-		return err
-	}
-
-	delete(structData, "extra_fields")
-
-	// not exist new fields will be added
-	for k, v := range data.ExtraFields {
-		if _, ok := structData[k]; !ok {
-			structData[k] = v
-		}
-	}
-
-	jsonStructDataByte, err := json.Marshal(structData)
-	if err != nil {
-		return err
-	}
-
-	jsonData := string(jsonStructDataByte)
+	// record fields are added as top-level keys, but never replace the msg fields,
+	// not exist extra fields will be added
+	k.buf.Reset()
+	k.formatter.encode(&k.buf, r)
+	jsonData := k.buf.String()
 
 	key := ""
 	if k.conf.Key != "" {
@@ -117,10 +121,13 @@ func (k *KafKaWriter) Write(r *Record) error {
 		// Timestamp: time.Now(),
 		Key:   sarama.ByteEncoder(key),
 		Value: sarama.ByteEncoder(jsonData),
+		// true if the record came from the log package, see kafkaLogf
+		Metadata: r.std,
 	}
 
-	if k.conf.Debug {
-		log.Printf("kafka-writer msg [topic: %v, timestamp: %v, brokers: %v]\nkey:   %v\nvalue: %v\n", msg.Topic,
+	// not for the records of the log package, they would come back here
+	if k.conf.Debug && !r.std {
+		logPrintf("kafka-writer msg [topic: %v, timestamp: %v, brokers: %v]\nkey:   %v\nvalue: %v\n", msg.Topic,
 			msg.Timestamp, k.conf.Brokers, key, jsonData)
 	}
 	go k.asyncWriteMessages(msg)
@@ -130,14 +137,25 @@ func (k *KafKaWriter) Write(r *Record) error {
 
 func (k *KafKaWriter) asyncWriteMessages(msg *sarama.ProducerMessage) {
 	if msg != nil {
-		k.messages <- msg
+		select {
+		case k.messages <- msg:
+		case <-k.stop:
+		}
+	}
+}
+
+// kafkaLogf log about the message with the log package, to stderr if the
+// message is a record of the log package, so a redirected log package can not loop
+func kafkaLogf(msg *sarama.ProducerMessage, format string, v ...interface{}) {
+	if std, _ := msg.Metadata.(bool); std {
+		fmt.Fprintf(os.Stderr, format, v...)
+		return
 	}
+	logPrintf(format, v...)
 }
 
 // send kafka message to kafka
 func (k *KafKaWriter) daemonProducer() {
-	k.run = true
-
 next:
 	for {
 		select {
@@ -146,12 +164,12 @@ next:
 				partition, offset, err := k.producer.SendMessage(mes)
 
 				if err != nil {
-					log.Printf("SendMessage(topic=%s, partition=%v, offset=%v, key=%s, value=%s,timstamp=%v) err=%s\n\n", mes.Topic,
+					kafkaLogf(mes, "SendMessage(topic=%s, partition=%v, offset=%v, key=%s, value=%s,timstamp=%v) err=%s\n\n", mes.Topic,
 						partition, offset, mes.Key, mes.Value, mes.Timestamp, err.Error())
 					continue
 				} else {
 					if k.conf.Debug {
-						log.Printf("SendMessage(topic=%s, partition=%v, offset=%v, key=%s, value=%s,timstamp=%v)\n\n", mes.Topic,
+						kafkaLogf(mes, "SendMessage(topic=%s, partition=%v, offset=%v, key=%s, value=%s,timstamp=%v)\n\n", mes.Topic,
 							partition, offset, mes.Key, mes.Value, mes.Timestamp)
 					}
 				}
@@ -160,12 +178,23 @@ next:
 			break next
 		}
 	}
-	k.quit <- struct{}{}
+	// send the queued messages before quit
+	for {
+		select {
+		case mes := <-k.messages:
+			if _, _, err := k.producer.SendMessage(mes); err != nil {
+				kafkaLogf(mes, "SendMessage(topic=%s, key=%s, value=%s) err=%s\n\n", mes.Topic, mes.Key, mes.Value, err.Error())
+			}
+		default:
+			k.quit <- struct{}{}
+			return
+		}
+	}
 }
 
 // Start start the kafka writer
 func (k *KafKaWriter) Start() (err error) {
-	log.Println("start kafka writer ...")
+	logPrintln("start kafka writer ...")
 	cfg := sarama.NewConfig()
 	cfg.Producer.Return.Successes = k.conf.ProducerReturnSuccesses
 	cfg.Producer.Timeout = k.conf.ProducerTimeout
@@ -199,7 +228,7 @@ func (k *KafKaWriter) Start() (err error) {
 
 	k.producer, err = sarama.NewSyncProducer(k.conf.Brokers, cfg)
 	if err != nil {
-		log.Printf("sarama.NewSyncProducer err, message=%s \n", err)
+		logPrintf("sarama.NewSyncProducer err, message=%s \n", err)
 		return err
 	}
 	size := k.conf.BufferSize
@@ -208,18 +237,36 @@ func (k *KafKaWriter) Start() (err error) {
 	}
 	k.messages = make(chan *sarama.ProducerMessage, size)
 
+	k.run = true
 	go k.daemonProducer()
-	log.Println("start kafka writer ok")
+	logPrintln("start kafka writer ok")
 	return err
 }
 
-// Stop stop the kafka writer
+// Stop stop the kafka writer, the queued messages are sent before
 func (k *KafKaWriter) Stop() {
 	if k.run {
-		close(k.messages)
-		<-k.stop
+		k.run = false
+		close(k.stop)
+		<-k.quit
 		if err := k.producer.Close(); err != nil {
-			log.Printf("stop kafka writer failed:%v\n", err)
+			logPrintf("stop kafka writer failed:%v\n", err)
 		}
 	}
 }
+
+// Close stop the kafka writer
+func (k *KafKaWriter) Close() error {
+	k.Stop()
+	return nil
+}
+
+// Level kafka writer get the level
+func (k *KafKaWriter) Level() int {
+	return int(atomic.LoadInt32(&k.level))
+}
+
+// SetLevel kafka writer set the level, safe to call while writing
+func (k *KafKaWriter) SetLevel(level int) {
+	atomic.StoreInt32(&k.level, int32(level))
+}
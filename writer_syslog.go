@@ -3,8 +3,10 @@
 package log4go
 
 import (
+	"bytes"
 	"errors"
 	"log/syslog"
+	"sync/atomic"
 )
 
 // ShortRecord short record
@@ -12,20 +14,28 @@ type ShortRecord Record
 
 // String string
 func (r *ShortRecord) String() string {
-	return "<" + r.code + "> " + r.info
+	if len(r.stack) > 0 {
+		return "<" + r.code + "> " + (*Record)(r).namedInfo() + fieldsString(r.fields) + "\n" + stackString(r.stack)
+	}
+	return "<" + r.code + "> " + (*Record)(r).namedInfo() + fieldsString(r.fields)
 }
 
 // SyslogWriter sys log writer
 type SyslogWriter struct {
-	level   int
+	level   int32 // accessed atomically
 	network string
 	addr    string
 	tag     string
 	writer  *syslog.Writer
+
+	formatter Formatter
+	buf       bytes.Buffer
 }
 
+// NewSyslogWriter create syslog writer at DEBUG, like the other writers
+// without a valid level, SetLevel(TRACE) to write the TRACE records too
 func NewSyslogWriter() *SyslogWriter {
-	return &SyslogWriter{}
+	return &SyslogWriter{level: DEBUG}
 }
 
 func (w *SyslogWriter) SetNetwork(network string) {
@@ -40,19 +50,30 @@ func (w *SyslogWriter) SetTag(tag string) {
 	w.tag = tag
 }
 
+// SetFormatter set the formatter, default "<code> info"
+func (w *SyslogWriter) SetFormatter(f Formatter) {
+	w.formatter = f
+}
+
 func (w *SyslogWriter) Init() (err error) {
 	w.writer, err = syslog.Dial(w.network, w.addr, syslog.LOG_SYSLOG, w.tag)
 	return
 }
 
 func (w *SyslogWriter) Write(r *Record) (err error) {
-	if r.level < w.level {
+	if int32(r.level) < atomic.LoadInt32(&w.level) {
 		return
 	}
-	s := ((*ShortRecord)(r)).String()
+	var s string
+	if w.formatter != nil {
+		formatRecord(&w.buf, w.formatter, r)
+		s = w.buf.String()
+	} else {
+		s = ((*ShortRecord)(r)).String()
+	}
 
 	switch r.level {
-	case DEBUG:
+	case TRACE, DEBUG:
 		err = w.writer.Debug(s)
 	case INFO:
 		err = w.writer.Info(s)
@@ -60,10 +81,28 @@ func (w *SyslogWriter) Write(r *Record) (err error) {
 		err = w.writer.Warning(s)
 	case ERROR:
 		err = w.writer.Err(s)
-	case FATAL:
+	case PANIC, FATAL:
 		err = w.writer.Crit(s)
 	default:
 		err = errors.New("invalid level")
 	}
 	return
 }
+
+// Level syslog writer get the level
+func (w *SyslogWriter) Level() int {
+	return int(atomic.LoadInt32(&w.level))
+}
+
+// SetLevel syslog writer set the level, safe to call while writing
+func (w *SyslogWriter) SetLevel(level int) {
+	atomic.StoreInt32(&w.level, int32(level))
+}
+
+// Close close the connection to the syslog daemon
+func (w *SyslogWriter) Close() error {
+	if w.writer == nil {
+		return nil
+	}
+	return w.writer.Close()
+}
@@ -0,0 +1,11 @@
+// +build !windows,!nacl,!plan9
+
+package log4go
+
+import "testing"
+
+func TestSyslogWriterDefaultLevel(t *testing.T) {
+	if level := NewSyslogWriter().Level(); level != DEBUG {
+		t.Fatalf("default level = %s, want DEBUG", LevelFlags[level])
+	}
+}